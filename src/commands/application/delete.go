@@ -0,0 +1,109 @@
+package application
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func deleteCommand() cli.Command {
+	return cli.Command{
+		Name:        "delete",
+		Usage:       "delete an application, archiving its keys",
+		ArgsUsage:   "ID",
+		Description: "This will remove an application's definition, license templates, certificates and keys from use, moving them into an archive folder within the certificate path so that licenses already issued for the application may still be investigated.",
+		Flags: []cli.Flag{
+			shared.CertPathFlag(),
+			cli.BoolFlag{
+				Name:  "yes",
+				Usage: "delete the application without asking for confirmation",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			licensePath := c.GlobalString("licensePath")
+			product, err := shared.LoadProduct(licensePath, c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			files, err := filepath.Glob(filepath.Join(shared.CertPath(c), fmt.Sprintf("%s.*", product.ID)))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			// When certificates are kept alongside the product's definition,
+			// the product file has already been matched by the glob above.
+			productFile := shared.ProductFile(licensePath, product.ID)
+			if !containsFile(files, productFile) {
+				files = append(files, productFile)
+			}
+
+			templates := shared.LicenseTemplates(licensePath, product.ID).Path
+			if _, err := os.Stat(templates); err == nil {
+				files = append(files, templates)
+			}
+
+			if !c.Bool("yes") {
+				fmt.Fprintf(os.Stderr, "Delete the application '%s' and archive its %d files? [y/N] ", product.ID, len(files))
+
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				answer = strings.ToLower(strings.TrimSpace(answer))
+				if answer != "y" && answer != "yes" {
+					return cli.NewExitError("the application was not deleted", 1)
+				}
+			}
+
+			archive := filepath.Join(shared.CertPath(c), "archive", fmt.Sprintf("%s-%s", product.ID, time.Now().UTC().Format("20060102T150405Z")))
+			err = os.MkdirAll(archive, 0700)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			for _, file := range files {
+				name := filepath.Base(file)
+				if file == templates {
+					name = "templates"
+				}
+
+				err = os.Rename(file, filepath.Join(archive, name))
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+			}
+
+			return shared.Print(c, &archivedProduct{ID: product.ID, Archive: archive})
+		},
+	}
+}
+
+func containsFile(files []string, file string) bool {
+	for _, f := range files {
+		if filepath.Clean(f) == filepath.Clean(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// archivedProduct describes where a deleted application was archived.
+type archivedProduct struct {
+	ID      string `json:"id"`
+	Archive string `json:"archive"`
+}
+
+func (p *archivedProduct) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "The application '%s' was archived in %s\n", p.ID, p.Archive)
+}
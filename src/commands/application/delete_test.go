@@ -0,0 +1,60 @@
+package application
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func TestDeleteDefaultLayout(t *testing.T) {
+	licensePath, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(licensePath)
+
+	err = shared.SaveProduct(licensePath, &license.Product{ID: "acme", Name: "Acme", Organization: "Acme Inc."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(licensePath, "acme.crt"), []byte("certificate"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exitCode := 0
+	cli.OsExiter = func(code int) { exitCode = code }
+	defer func() { cli.OsExiter = os.Exit }()
+
+	app := cli.NewApp()
+	app.Writer = ioutil.Discard
+	app.Commands = []cli.Command{Command()}
+	app.Flags = []cli.Flag{
+		shared.LicensePathFlag(),
+		shared.OutputFlag(),
+	}
+
+	err = app.Run([]string{"litmus", "--licensePath", licensePath, "--output", "json", "app", "delete", "--yes", "acme"})
+	if err != nil || exitCode != 0 {
+		t.Fatalf("expected the application to be deleted, got exit code %d: %v", exitCode, err)
+	}
+
+	archived, err := filepath.Glob(filepath.Join(licensePath, "archive", "acme-*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(archived) != 2 {
+		t.Errorf("expected the product file and certificate to be archived, got %v", archived)
+	}
+
+	if _, err := os.Stat(shared.ProductFile(licensePath, "acme")); !os.IsNotExist(err) {
+		t.Errorf("expected the product file to be removed, got %v", err)
+	}
+}
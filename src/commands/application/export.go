@@ -0,0 +1,47 @@
+package application
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func exportCommand() cli.Command {
+	return cli.Command{
+		Name:        "export",
+		Usage:       "export an application and its certificate chain",
+		ArgsUsage:   "ID FILE",
+		Description: "This will write an application's definition and certificate chain to FILE so that it may be imported elsewhere. Private keys are never included.",
+		Flags: []cli.Flag{
+			shared.CertPathFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the ID of the application and the file to export it to")
+			}
+
+			product, err := shared.LoadProduct(c.GlobalString("licensePath"), c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm := license.NewCertManager(product)
+			cm.Path = shared.CertPath(c)
+
+			chain, err := cm.GetChain()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := license.EncodeProductBundle(product, chain)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return ioutil.WriteFile(c.Args()[1], data, 0644)
+		},
+	}
+}
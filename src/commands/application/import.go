@@ -0,0 +1,72 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:        "import",
+		Usage:       "import an application and its certificate chain",
+		ArgsUsage:   "FILE",
+		Description: "This will import an application's definition and certificate chain from a file produced by the export command. The application's keys must be copied separately, and referenced using --key, if it is to issue licenses.",
+		Flags: []cli.Flag{
+			shared.CertPathFlag(),
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "the key `file`, relative to the certificate path, or PKCS#11 reference used by the imported certificate chain",
+			},
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "replace an existing application with the same ID",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the file to import")
+			}
+
+			data, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			product, chain, err := license.ParseProductBundle(data)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("invalid application bundle: %s", err), 1)
+			}
+
+			licensePath := c.GlobalString("licensePath")
+			if _, err := shared.LoadProduct(licensePath, product.ID); err == nil && !c.Bool("force") {
+				return cli.NewExitError(fmt.Sprintf("the application '%s' already exists, use --force to replace it", product.ID), 1)
+			}
+
+			err = shared.SaveProduct(licensePath, product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if chain == nil {
+				return nil
+			}
+
+			chain.KeyRef = c.String("key")
+
+			cm := license.NewCertManager(product)
+			cm.Path = shared.CertPath(c)
+
+			err = cm.SetChain(chain)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
@@ -1,13 +1,10 @@
 package application
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
-	"path/filepath"
 
-	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
 	"github.com/codegangsta/cli"
 )
 
@@ -16,59 +13,16 @@ func listCommand() cli.Command {
 		Name:  "list",
 		Usage: "retrieve a list of applications you are in posession of certificates for",
 		Action: func(c *cli.Context) error {
-			products, err := productsList(c.GlobalString("licensePath"))
+			products, skipped, err := shared.ListProducts(c.GlobalString("licensePath"))
 			if err != nil {
 				return cli.NewExitError("could not read license directory", 1)
 			}
 
-			fmt.Printf("%-20s %-25s %-25s\n", "ID", "Name", "Organization")
-
-			for _, p := range products {
-				fmt.Printf("%-20s %-25s %-25s\n", p.ID, p.Name, p.Organization)
+			for _, file := range skipped {
+				fmt.Fprintf(os.Stderr, "skipping '%s' as it is not a valid application definition\n", file)
 			}
 
-			return nil
+			return shared.Print(c, shared.ProductList(products))
 		},
 	}
 }
-
-func productsList(licensePath string) ([]*license.Product, error) {
-	productFiles, err := filepath.Glob(filepath.Join(licensePath, "*.json"))
-	if err != nil {
-		return nil, err
-	}
-
-	products := make([]*license.Product, len(productFiles))
-	for i := 0; i < len(products); i++ {
-		product, err := parseProductFile(productFiles[i])
-		if err != nil {
-			return nil, err
-		}
-
-		products[i] = product
-	}
-
-	return products, nil
-}
-
-func parseProductFile(file string) (*license.Product, error) {
-	var product license.Product
-
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
-	}
-
-	err = json.Unmarshal(data, &product)
-	if err != nil {
-		return nil, err
-	}
-
-	return &product, nil
-}
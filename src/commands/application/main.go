@@ -13,8 +13,15 @@ func Command() cli.Command {
 		Usage:     "manage configuration and licensing of an application",
 		Subcommands: cli.Commands{
 			listCommand(),
+			showCommand(),
 			newAppCommand(),
+			updateCommand(),
+			deleteCommand(),
+			exportCommand(),
+			importCommand(),
 			newCertCommand(),
+			newIntermediateCommand(),
+			unlockCommand(),
 		},
 	}
 }
@@ -1,13 +1,9 @@
 package application
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
 	"github.com/SierraSoftworks/Lithium/src/license"
 	"github.com/codegangsta/cli"
 )
@@ -19,6 +15,7 @@ func newAppCommand() cli.Command {
 		Usage:       "create a new application to accept licenses",
 		ArgsUsage:   "ID NAME ORGANIZATION",
 		Description: "This will create a new application description file which is used by the Lithium command line tool to track details about the application for licensing purposes.",
+		Flags:       productFlags(),
 		Action: func(c *cli.Context) error {
 			if c.NArg() < 3 {
 				return errors.New("expected you to provide the ID, name and organization of the product")
@@ -32,7 +29,9 @@ func newAppCommand() cli.Command {
 				Organization: args[2],
 			}
 
-			err := saveProduct(&product, c.GlobalString("licensePath"))
+			applyProductFlags(c, &product)
+
+			err := shared.SaveProduct(c.GlobalString("licensePath"), &product)
 			if err != nil {
 				return cli.NewExitError(err.Error(), 1)
 			}
@@ -41,17 +40,3 @@ func newAppCommand() cli.Command {
 		},
 	}
 }
-
-func saveProduct(prod *license.Product, licensePath string) error {
-	err := os.MkdirAll(licensePath, os.ModePerm|os.ModeDir)
-	if err != nil {
-		return err
-	}
-
-	data, err := json.Marshal(prod)
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(filepath.Join(licensePath, fmt.Sprintf("./%s.json", prod.ID)), data, os.ModePerm)
-}
@@ -1,31 +1,28 @@
 package application
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
-	"encoding/pem"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
 	"path/filepath"
 
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
 	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/license/hsm"
 	"github.com/codegangsta/cli"
 )
 
 func newCertCommand() cli.Command {
-	cwd, err := filepath.Abs("./")
-	if err != nil {
-		cwd = os.ExpandEnv("$HOME")
-	}
-
 	return cli.Command{
 		Name:        "root",
 		Usage:       "create a new root certificate for an application",
 		Description: "This will create a new root certificate with the details of the application it will sign licenses for.",
 		ArgsUsage:   "ID NAME",
-		Flags: []cli.Flag{
+		Flags: append(append(productFlags(), shared.PassphraseFlags()...),
 			cli.StringFlag{
 				Name:   "id",
 				EnvVar: "APP_ID",
@@ -41,70 +38,175 @@ func newCertCommand() cli.Command {
 				EnvVar: "APP_ORGANIZATION",
 				Usage:  "the name of the organization who manages the application you're generating a certificate for",
 			},
-			cli.StringFlag{
-				Name:   "path",
-				EnvVar: "LITHIUM_LICENSE_PATH",
-				Usage:  "the folder within which your Lithium licenses are stored",
-				Value:  filepath.Join(cwd, "licenses"),
+			shared.CertPathFlag(),
+			shared.KeySizeFlag(4096),
+			cli.IntFlag{
+				Name:  "shares",
+				Usage: "split the root key into this many shares rather than writing it to a single file",
 			},
 			cli.IntFlag{
-				Name:   "keySize",
-				EnvVar: "LITHIUM_KEY_SIZE",
-				Usage:  "the length of the secure key used for the certificate",
-				Value:  4096,
+				Name:  "threshold",
+				Usage: "the number of shares required to recover the root key",
 			},
-		},
+			cli.StringFlag{
+				Name:   "pkcs11Module",
+				EnvVar: "LITHIUM_PKCS11_MODULE",
+				Usage:  "the `path` to a PKCS#11 module which should be used to generate and hold the root key",
+			},
+			cli.StringFlag{
+				Name:   "pkcs11Token",
+				EnvVar: "LITHIUM_PKCS11_TOKEN",
+				Usage:  "the label of the PKCS#11 token on which the root key should be generated",
+			},
+			cli.StringFlag{
+				Name:   "pkcs11Pin",
+				EnvVar: "LITHIUM_PKCS11_PIN",
+				Usage:  "the user PIN for the PKCS#11 token",
+			},
+			cli.StringFlag{
+				Name:   "pkcs11Key",
+				EnvVar: "LITHIUM_PKCS11_KEY",
+				Usage:  "the label of the key on the PKCS#11 token, defaults to the application ID",
+			},
+		),
 		Action: func(c *cli.Context) error {
 			id := c.String("id")
 			name := c.String("name")
 			org := c.String("org")
-			path := c.String("path")
+			path := shared.CertPath(c)
 
-			if id == "" || name == "" || org == "" {
-				return fmt.Errorf("expected you to provide a name and ID for the application as well as a name for your organization")
+			if id == "" {
+				return fmt.Errorf("expected you to provide the ID of the application")
 			}
 
 			product := license.Product{
-				ID:           id,
-				Name:         name,
-				Organization: org,
+				ID: id,
 			}
 
-			privKey, err := rsa.GenerateKey(rand.Reader, c.Int("keySize"))
-			if err != nil {
-				return err
+			if existing, err := shared.LoadProduct(c.GlobalString("licensePath"), id); err == nil {
+				product = *existing
+			}
+
+			if name != "" {
+				product.Name = name
+			}
+
+			if org != "" {
+				product.Organization = org
 			}
 
+			if product.Name == "" || product.Organization == "" {
+				return fmt.Errorf("expected you to provide a name and ID for the application as well as a name for your organization")
+			}
+
+			applyProductFlags(c, &product)
+
 			cm := license.NewCertManager(&product)
 
 			if path != "" {
 				cm.Path = path
 			}
 
-			cert, err := cm.CreateRoot(privKey)
+			if c.Int("shares") > 0 && c.Int("threshold") == 0 {
+				return fmt.Errorf("expected you to provide the threshold of shares required to recover the key")
+			}
+
+			if c.String("pkcs11Module") != "" {
+				token, err := hsm.Open(&hsm.Config{
+					Module:     c.String("pkcs11Module"),
+					TokenLabel: c.String("pkcs11Token"),
+					PIN:        c.String("pkcs11Pin"),
+				})
+				if err != nil {
+					return err
+				}
+				defer token.Close()
+
+				keyLabel := c.String("pkcs11Key")
+				if keyLabel == "" {
+					keyLabel = product.ID
+				}
+
+				privKey, err := token.GenerateRSAKey(keyLabel, c.Int("keySize"))
+				if err != nil {
+					return err
+				}
+
+				return writeRootCertificate(cm, privKey, hsm.KeyRef(keyLabel))
+			}
+
+			privKey, err := rsa.GenerateKey(rand.Reader, c.Int("keySize"))
 			if err != nil {
 				return err
 			}
 
-			err = ioutil.WriteFile(filepath.Join(cm.Path, fmt.Sprintf("%s.key", product.ID)), pem.EncodeToMemory(&pem.Block{
-				Type:  license.PrivateKeyType,
-				Bytes: x509.MarshalPKCS1PrivateKey(privKey),
-			}), os.ModePerm)
+			if c.Int("shares") > 0 {
+				files, err := writeRootKeyShares(cm, privKey, c.Int("shares"), c.Int("threshold"))
+				if err != nil {
+					return err
+				}
+
+				return shared.Print(c, keyShares(files))
+			}
 
+			passphrase, err := shared.Passphrase(c, "Enter a passphrase to protect the root key: ", true)
 			if err != nil {
 				return err
 			}
 
-			err = ioutil.WriteFile(filepath.Join(cm.Path, fmt.Sprintf("%s.crt", product.ID)), pem.EncodeToMemory(&pem.Block{
-				Type:  license.CertificateType,
-				Bytes: cert.Raw,
-			}), os.ModePerm)
-
+			keyFile := fmt.Sprintf("%s.key", product.ID)
+			err = writeRootCertificate(cm, privKey, keyFile)
 			if err != nil {
 				return err
 			}
 
-			return nil
+			return cm.WriteKey(keyFile, privKey, passphrase)
 		},
 	}
 }
+
+func writeRootCertificate(cm *license.CertManager, privKey crypto.Signer, keyRef string) error {
+	cert, err := cm.CreateRoot(privKey)
+	if err != nil {
+		return err
+	}
+
+	return cm.SetChain(&license.Chain{
+		Certificates: []*x509.Certificate{cert},
+		KeyRef:       keyRef,
+	})
+}
+
+func writeRootKeyShares(cm *license.CertManager, privKey *rsa.PrivateKey, shares, threshold int) ([]string, error) {
+	data, err := cm.SplitKey(privKey, shares, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeRootCertificate(cm, privKey, license.KeySharesRef)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for i, share := range data {
+		file := filepath.Join(cm.Path, fmt.Sprintf("%s.share.%d.pem", cm.Product.ID, i+1))
+		err = ioutil.WriteFile(file, share, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+// keyShares lists the files holding the shares of a root key.
+type keyShares []string
+
+func (s keyShares) WriteTable(w io.Writer) {
+	for _, file := range s {
+		fmt.Fprintln(w, file)
+	}
+}
@@ -0,0 +1,112 @@
+package application
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func newIntermediateCommand() cli.Command {
+	return cli.Command{
+		Name:        "intermediate",
+		Usage:       "create a delegated certificate for a license server",
+		Description: "This will create a new intermediate certificate, signed by the application's root certificate, which allows a license server to issue licenses for the application. The root key is unlocked using --passphrase or --passphraseFd, while the new intermediate key is protected by the separate passphrase provided by --newPassphrase or --newPassphraseFd, or entered and confirmed at a prompt.",
+		ArgsUsage:   "ID NAME",
+		Flags: append(append(shared.PassphraseFlags(), shared.NewPassphraseFlags()...),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.KeySizeFlag(4096),
+			cli.IntFlag{
+				Name:  "pathLen",
+				Usage: "the number of further intermediate certificates which may be issued beneath this one",
+				Value: 0,
+			},
+			cli.DurationFlag{
+				Name:  "validity",
+				Usage: "the `duration` for which the certificate will be valid, limited by the root certificate",
+				Value: 365 * 24 * time.Hour,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the ID of the application and a name for the license server")
+			}
+
+			id := c.Args()[0]
+			name := c.Args()[1]
+
+			product, err := shared.LoadProduct(c.GlobalString("licensePath"), id)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, err := shared.CertManager(c, product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			rootChain, err := cm.GetChain()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if rootChain == nil {
+				return cli.NewExitError(fmt.Sprintf("no certificate exists for application '%s'", id), 1)
+			}
+
+			err = shared.UnlockKey(c, cm)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			rootKey, closeKey, err := shared.LoadSigningKey(cm, rootChain)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			privKey, err := rsa.GenerateKey(rand.Reader, c.Int("keySize"))
+			if err != nil {
+				return err
+			}
+
+			cert, err := cm.CreateIntermediate(rootChain.Leaf(), rootKey, privKey.Public(), &license.IntermediateOptions{
+				Name:       name,
+				MaxPathLen: c.Int("pathLen"),
+				NotAfter:   time.Now().Add(c.Duration("validity")),
+			})
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			passphrase, err := shared.NewPassphrase(c, "Enter a passphrase to protect the intermediate key: ")
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			keyFile := fmt.Sprintf("%s.%s.key", id, name)
+			err = cm.WriteKey(keyFile, privKey, passphrase)
+			if err != nil {
+				return err
+			}
+
+			chain, err := license.EncodeChain(&license.Chain{
+				Certificates: append(rootChain.Certificates, cert),
+				KeyRef:       keyFile,
+			})
+			if err != nil {
+				return err
+			}
+
+			return ioutil.WriteFile(filepath.Join(cm.Path, fmt.Sprintf("%s.%s.crt", id, name)), chain, 0644)
+		},
+	}
+}
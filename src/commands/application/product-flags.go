@@ -0,0 +1,81 @@
+package application
+
+import (
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// productFlags returns the flags used to describe how an application's
+// certificates should be named.
+func productFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "unit",
+			Usage: "the organizational unit which issues licenses for the application",
+		},
+		cli.StringFlag{
+			Name:  "locality",
+			Usage: "the city or locality of the issuing organization",
+		},
+		cli.StringFlag{
+			Name:  "province",
+			Usage: "the state or province of the issuing organization",
+		},
+		cli.StringFlag{
+			Name:  "country",
+			Usage: "the country of the issuing organization",
+		},
+		cli.IntFlag{
+			Name:  "rootValidity",
+			Usage: "the number of `days` for which the application's root certificate is valid",
+		},
+		cli.StringSliceFlag{
+			Name:  "keyUsage",
+			Usage: "a key usage to grant the application's root certificate, for example certSign",
+		},
+		cli.StringSliceFlag{
+			Name:  "dnsName",
+			Usage: "a DNS name to include in the application's root certificate",
+		},
+		cli.StringSliceFlag{
+			Name:  "uri",
+			Usage: "a URI to include in the application's root certificate",
+		},
+	}
+}
+
+// applyProductFlags updates a product with any of the values provided
+// through the flags returned by productFlags.
+func applyProductFlags(c *cli.Context, product *license.Product) {
+	if c.IsSet("unit") {
+		product.OrganizationalUnit = c.String("unit")
+	}
+
+	if c.IsSet("locality") {
+		product.Locality = c.String("locality")
+	}
+
+	if c.IsSet("province") {
+		product.Province = c.String("province")
+	}
+
+	if c.IsSet("country") {
+		product.Country = c.String("country")
+	}
+
+	if c.IsSet("rootValidity") {
+		product.RootValidityDays = c.Int("rootValidity")
+	}
+
+	if c.IsSet("keyUsage") {
+		product.KeyUsage = c.StringSlice("keyUsage")
+	}
+
+	if c.IsSet("dnsName") {
+		product.DNSNames = c.StringSlice("dnsName")
+	}
+
+	if c.IsSet("uri") {
+		product.URIs = c.StringSlice("uri")
+	}
+}
@@ -0,0 +1,46 @@
+package application
+
+import (
+	"errors"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func showCommand() cli.Command {
+	return cli.Command{
+		Name:        "show",
+		Usage:       "show the details of an application",
+		ArgsUsage:   "ID",
+		Description: "This will show an application's definition along with the details of its certificate chain, if one has been created.",
+		Flags: []cli.Flag{
+			shared.CertPathFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			product, err := shared.LoadProduct(c.GlobalString("licensePath"), c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm := license.NewCertManager(product)
+			cm.Path = shared.CertPath(c)
+
+			chain, err := cm.GetChain()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			result := &shared.ProductOutput{Product: product}
+			if chain != nil {
+				result.Chain = shared.NewChainOutput(chain, cm.ChainPath())
+			}
+
+			return shared.Print(c, result)
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func unlockCommand() cli.Command {
+	return cli.Command{
+		Name:        "unlock",
+		Usage:       "verify that an application's root key can be unlocked",
+		Description: "This will decrypt an application's root key, or recombine the provided shares of it, in memory and verify that it matches the application's certificate. The same passphrase or shares may be provided to any signing command.",
+		ArgsUsage:   "ID",
+		Flags: append(shared.PassphraseFlags(),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			id := c.Args()[0]
+
+			product, err := shared.LoadProduct(c.GlobalString("licensePath"), id)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, err := shared.CertManager(c, product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = shared.UnlockKey(c, cm)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if cm.UnlockedKey() == nil {
+				return cli.NewExitError(fmt.Sprintf("the root key for '%s' is not held in a file or shares", id), 1)
+			}
+
+			return shared.Print(c, &unlockedKey{ID: id})
+		},
+	}
+}
+
+// unlockedKey describes the root key of an application which was unlocked.
+type unlockedKey struct {
+	ID string `json:"id"`
+}
+
+func (k *unlockedKey) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "The root key for '%s' was unlocked\n", k.ID)
+}
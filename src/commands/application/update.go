@@ -0,0 +1,55 @@
+package application
+
+import (
+	"errors"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func updateCommand() cli.Command {
+	return cli.Command{
+		Name:        "update",
+		Usage:       "update the definition of an application",
+		ArgsUsage:   "ID",
+		Description: "This will update the details of an existing application. Changes to certificate settings take effect the next time a root certificate is created for the application.",
+		Flags: append(productFlags(),
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "the name of the application",
+			},
+			cli.StringFlag{
+				Name:  "org",
+				Usage: "the name of the organization who manages the application",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			licensePath := c.GlobalString("licensePath")
+			product, err := shared.LoadProduct(licensePath, c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if c.IsSet("name") {
+				product.Name = c.String("name")
+			}
+
+			if c.IsSet("org") {
+				product.Organization = c.String("org")
+			}
+
+			applyProductFlags(c, product)
+
+			err = shared.SaveProduct(licensePath, product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
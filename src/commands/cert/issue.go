@@ -0,0 +1,98 @@
+package cert
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func issueCommand() cli.Command {
+	return cli.Command{
+		Name:        "issue",
+		Usage:       "issue a certificate for a certificate signing request",
+		ArgsUsage:   "REQUEST OUTPUT",
+		Description: "This will verify a certificate signing request and issue a certificate for its key, signed by the application's certificate, writing the full certificate chain to the output file.",
+		Flags: append(append(shared.PassphraseFlags(), shared.MetadataFlags()...),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.ProductFlag("the ID of the application for which the certificate should be issued"),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the request file and the file to which the certificate should be written")
+			}
+
+			if c.String("product") == "" {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			product, err := shared.LoadProduct(c.GlobalString("licensePath"), c.String("product"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			csrData, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			csr, err := license.ParseCertificateRequest(csrData)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("invalid certificate request: %s", err), 1)
+			}
+
+			meta, err := shared.LicenseMetadata(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, err := shared.CertManager(c, product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			chain, err := cm.GetChain()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if chain == nil {
+				return cli.NewExitError(fmt.Sprintf("no certificate exists for application '%s'", product.ID), 1)
+			}
+
+			err = shared.UnlockKey(c, cm)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			key, closeKey, err := shared.LoadSigningKey(cm, chain)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			template, err := cm.Prepare(csr, &license.Data{Meta: meta})
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cert, err := cm.Sign(template, key)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := license.EncodeChain(&license.Chain{
+				Certificates: append(chain.Certificates, cert),
+			})
+			if err != nil {
+				return err
+			}
+
+			return ioutil.WriteFile(c.Args()[1], data, 0644)
+		},
+	}
+}
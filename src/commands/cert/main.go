@@ -0,0 +1,18 @@
+package cert
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+// Command returns the cert command group, which is used to request and
+// issue certificates for machine and server keys.
+func Command() cli.Command {
+	return cli.Command{
+		Name:  "cert",
+		Usage: "request and issue certificates for machine and server keys",
+		Subcommands: cli.Commands{
+			requestCommand(),
+			issueCommand(),
+		},
+	}
+}
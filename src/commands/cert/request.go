@@ -0,0 +1,44 @@
+package cert
+
+import (
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func requestCommand() cli.Command {
+	return cli.Command{
+		Name:        "request",
+		Usage:       "create a certificate signing request for this machine's key",
+		ArgsUsage:   "FILE",
+		Description: "This will write a certificate signing request, signed by this machine's private key, which may be provided to an issuer to obtain a certificate.",
+		Flags: append(shared.KeyFlags(),
+			cli.StringFlag{
+				Name:  "name",
+				Usage: "the common name which should be included in the request",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the file to which the request should be written")
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			csr, err := km.CreateCertificateRequest(pkix.Name{
+				CommonName: c.String("name"),
+			})
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return ioutil.WriteFile(c.Args()[0], csr, 0644)
+		},
+	}
+}
@@ -3,6 +3,11 @@ package commands
 import (
 	// Import the application commands list
 	"github.com/SierraSoftworks/Lithium/src/commands/application"
+	"github.com/SierraSoftworks/Lithium/src/commands/cert"
+	"github.com/SierraSoftworks/Lithium/src/commands/config"
+	"github.com/SierraSoftworks/Lithium/src/commands/licenses"
+	"github.com/SierraSoftworks/Lithium/src/commands/machine"
+	"github.com/SierraSoftworks/Lithium/src/commands/serve"
 	"github.com/codegangsta/cli"
 )
 
@@ -18,4 +23,9 @@ func RegisterCommand(c cli.Command) {
 
 func init() {
 	RegisterCommand(application.Command())
+	RegisterCommand(machine.Command())
+	RegisterCommand(cert.Command())
+	RegisterCommand(licenses.Command())
+	RegisterCommand(serve.Command())
+	RegisterCommand(config.Command())
 }
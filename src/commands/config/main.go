@@ -0,0 +1,17 @@
+package config
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+// Command returns the config command group, which is used to examine the
+// configuration files read by litmus.
+func Command() cli.Command {
+	return cli.Command{
+		Name:  "config",
+		Usage: "examine the configuration used by litmus",
+		Subcommands: cli.Commands{
+			showCommand(),
+		},
+	}
+}
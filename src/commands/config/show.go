@@ -0,0 +1,22 @@
+package config
+
+import (
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func showCommand() cli.Command {
+	return cli.Command{
+		Name:        "show",
+		Usage:       "show the settings read from configuration files",
+		Description: "This will show the settings read from the system, user and workspace configuration files, along with the files which were found. Flags and environment variables override these settings.",
+		Action: func(c *cli.Context) error {
+			config, files, err := shared.LoadConfig()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, &shared.ConfigOutput{Config: config, Files: files})
+		},
+	}
+}
@@ -0,0 +1,389 @@
+package licenses
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// batchReport summarizes the outcome of a batch command.
+type batchReport struct {
+	Manifest string         `json:"manifest"`
+	Product  string         `json:"product"`
+	Issued   int            `json:"issued"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Licenses []*batchResult `json:"licenses"`
+
+	file string
+}
+
+func (r *batchReport) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "Issued %d, skipped %d and failed %d licenses, see %s\n", r.Issued, r.Skipped, r.Failed, r.file)
+}
+
+// batchResult describes the outcome for a single manifest entry, which is
+// one of "issued", "skipped" or "failed".
+type batchResult struct {
+	Row      int    `json:"row"`
+	ID       string `json:"id,omitempty"`
+	Customer string `json:"customer,omitempty"`
+	File     string `json:"file,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+func batchCommand() cli.Command {
+	return cli.Command{
+		Name:      "batch",
+		Usage:     "issue the licenses described by a manifest",
+		ArgsUsage: "MANIFEST",
+		Description: `This will issue a license for each entry in a JSON or CSV manifest, writing each license
+   container to <dir>/<id>.lic and a summary report to the report file. Licenses which have
+   already been written by a previous run are skipped if the product's ledger shows that they
+   were issued to the same public key with the same payload, so the command may safely be run
+   again after a failure. Entries whose file holds any other license are marked as failed. The manifest's entries may provide the following fields, with any additional
+   CSV columns being included in the license payload.
+
+     id         the license ID, derived from the entry's other fields if not provided
+     customer   the customer's name, included in the license payload
+     publicKey  the machine's public key file, relative to the manifest, or its PEM encoding
+     template   the name of the license template providing the license's defaults
+     activates  the time (RFC3339) from which the license is valid, defaults to now
+     expires    the time (RFC3339) at which the license expires, overrides duration
+     duration   the duration for which the license is valid, for example 8760h
+     payload    an object containing the license payload (JSON manifests only)`,
+		Flags: append(shared.PassphraseFlags(),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.ProductFlag("the ID of the application for which the licenses should be issued"),
+			shared.SigningHashFlag("the hash algorithm used to sign the licenses, one of sha1, sha256 or sha512"),
+			cli.StringFlag{
+				Name:  "dir",
+				Usage: "the `folder` to which license containers should be written",
+				Value: "issued",
+			},
+			cli.StringFlag{
+				Name:  "report",
+				Usage: "the `file` to which the summary report should be written, defaults to report.json within --dir",
+			},
+			cli.IntFlag{
+				Name:  "workers",
+				Usage: "the number of licenses which may be signed concurrently",
+				Value: runtime.NumCPU(),
+			},
+			cli.DurationFlag{
+				Name:  "duration",
+				Usage: "the `duration` for which licenses are valid when their entry does not specify one",
+				Value: 365 * 24 * time.Hour,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the manifest file")
+			}
+
+			if c.String("product") == "" {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			manifest := c.Args()[0]
+			entries, err := readManifest(manifest)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, key, closeKey, err := shared.LicenseIssuer(c, c.String("product"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			chain, err := cm.GetChain()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			dir := c.String("dir")
+			err = os.MkdirAll(dir, 0755)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			report := &batchReport{
+				Manifest: manifest,
+				Product:  cm.Product.ID,
+				Licenses: make([]*batchResult, len(entries)),
+			}
+
+			issued, err := issuedLicenses(cm.Ledger)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			store := shared.LicenseTemplates(c.GlobalString("licensePath"), cm.Product.ID)
+			templates := map[string]*license.LicenseTemplate{}
+
+			requests := []*license.BatchRequest{}
+			pending := []*batchResult{}
+			seen := map[string]bool{}
+
+			for i, entry := range entries {
+				result := &batchResult{
+					Row:      i + 1,
+					Customer: entry.Customer,
+				}
+				report.Licenses[i] = result
+
+				var template *license.LicenseTemplate
+				if entry.Template != "" {
+					template = templates[entry.Template]
+					if template == nil {
+						template, err = license.ResolveTemplate(store, entry.Template)
+						if err != nil {
+							result.fail(err)
+							continue
+						}
+
+						templates[entry.Template] = template
+					}
+				}
+
+				req, err := batchRequest(c, cm.Product.ID, filepath.Dir(manifest), entry, template)
+				if err != nil {
+					result.fail(err)
+					continue
+				}
+
+				result.ID = req.Data.Meta.ID
+				result.File = filepath.Join(dir, result.ID+license.InstalledLicenseExtension)
+
+				if seen[result.ID] {
+					result.fail(fmt.Errorf("the license ID '%s' is used by another entry", result.ID))
+					continue
+				}
+				seen[result.ID] = true
+
+				skip, err := alreadyIssued(result.File, chain.Root(), cm.Product.ID, req, issued[result.ID])
+				if err != nil {
+					result.fail(err)
+					continue
+				}
+
+				if skip {
+					result.Status = "skipped"
+					continue
+				}
+
+				requests = append(requests, req)
+				pending = append(pending, result)
+			}
+
+			err = cm.IssueBatch(requests, key, c.String("algorithm"), c.Int("workers"), func(index int, container *license.Container, err error) error {
+				result := pending[index]
+				if err == nil {
+					err = writeContainer(result.File, container)
+				}
+
+				if err != nil {
+					result.fail(err)
+					return nil
+				}
+
+				result.Status = "issued"
+				return nil
+			})
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			for _, result := range report.Licenses {
+				switch result.Status {
+				case "issued":
+					report.Issued++
+				case "skipped":
+					report.Skipped++
+				default:
+					report.Failed++
+				}
+			}
+
+			reportFile := c.String("report")
+			if reportFile == "" {
+				reportFile = filepath.Join(dir, "report.json")
+			}
+
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(reportFile, data, 0644)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			report.file = reportFile
+			err = shared.Print(c, report)
+			if err != nil {
+				return err
+			}
+
+			if report.Failed > 0 {
+				return cli.NewExitError(fmt.Sprintf("%d licenses could not be issued", report.Failed), 1)
+			}
+
+			return nil
+		},
+	}
+}
+
+func (r *batchResult) fail(err error) {
+	r.Status = "failed"
+	r.Error = err.Error()
+}
+
+// batchRequest prepares the license described by a manifest entry, applying
+// the entry's license template if it has one.
+func batchRequest(c *cli.Context, productID, dir string, entry *manifestEntry, template *license.LicenseTemplate) (*license.BatchRequest, error) {
+	pubKey, err := entry.publicKey(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %s", err)
+	}
+
+	id, err := entry.licenseID(productID, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := c.Duration("duration")
+	if template != nil && template.ValidityDays > 0 && !c.IsSet("duration") {
+		duration = template.Validity()
+	}
+
+	if entry.Duration != "" {
+		duration, err = time.ParseDuration(entry.Duration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration: %s", err)
+		}
+	}
+
+	activatesOn, expiresOn, err := shared.ValidityPeriod(entry.Activates, entry.Expires, duration)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{}
+	for k, v := range entry.Payload {
+		payload[k] = v
+	}
+
+	if _, ok := payload["customer"]; !ok && entry.Customer != "" {
+		payload["customer"] = entry.Customer
+	}
+
+	data := &license.Data{
+		Meta: &license.Metadata{
+			ID:              id,
+			ActivatesOn:     activatesOn,
+			ExpiresOn:       expiresOn,
+			LicenseTemplate: entry.Template,
+		},
+		Payload: payload,
+	}
+
+	if template != nil {
+		template.Apply(data)
+	}
+
+	return &license.BatchRequest{
+		Data:      data,
+		PublicKey: pubKey,
+	}, nil
+}
+
+// issuedLicenses retrieves the most recent ledger entry for each license
+// ID which has been issued, if a ledger has been configured.
+func issuedLicenses(ledger license.Ledger) (map[string]*license.LedgerEntry, error) {
+	issued := map[string]*license.LedgerEntry{}
+	if ledger == nil {
+		return issued, nil
+	}
+
+	entries, err := ledger.Entries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.Kind == license.LedgerLicense && entry.LicenseID != "" {
+			issued[entry.LicenseID] = entry
+		}
+	}
+
+	return issued, nil
+}
+
+// alreadyIssued determines whether the license described by a request has
+// already been written to the given file. Since the container's contents
+// can only be read by the machine it was issued to, the ledger entry which
+// recorded its issue must match the request's public key and payload. An
+// error is returned if the file holds any other license, so that it is not
+// overwritten.
+func alreadyIssued(file string, root *x509.Certificate, productID string, req *license.BatchRequest, entry *license.LedgerEntry) (bool, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	conflict := func(reason string) error {
+		return fmt.Errorf("conflicts with the existing license %s, which %s", file, reason)
+	}
+
+	container, err := license.ParseContainer(data)
+	if err != nil {
+		return false, conflict("could not be read")
+	}
+
+	if _, err := container.IsValidFor(root, productID); err != nil {
+		return false, conflict(fmt.Sprintf("is not valid: %s", err))
+	}
+
+	if entry == nil {
+		return false, conflict("is not recorded in the product's ledger")
+	}
+
+	subjectKey, err := license.KeyFingerprint(req.PublicKey)
+	if err != nil {
+		return false, err
+	}
+
+	if entry.SubjectKey != subjectKey {
+		return false, conflict("was issued to a different public key")
+	}
+
+	digest, err := license.PayloadDigest(req.Data.Payload)
+	if err != nil {
+		return false, err
+	}
+
+	if entry.PayloadHash != hex.EncodeToString(digest) {
+		return false, conflict("was issued with a different payload")
+	}
+
+	return true, nil
+}
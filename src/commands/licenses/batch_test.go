@@ -0,0 +1,125 @@
+package licenses
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+func TestBatchAlreadyIssued(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	km := license.NewKeyManager([]byte("test"))
+	km.Path = dir
+	km.KeySize = 1024
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := license.NewKeyManager([]byte("test"))
+	other.Path = dir
+	other.KeySize = 1024
+	other.Profile = "other"
+
+	otherKey, err := other.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm := license.NewCertManager(&license.Product{ID: "testing", Name: "Testing", Organization: "Lithium Testing"})
+	cm.Path = dir
+	cm.Ledger = license.NewMemoryLedger()
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(customer string) *license.BatchRequest {
+		return &license.BatchRequest{
+			Data: &license.Data{
+				Meta: &license.Metadata{
+					ID:          "batch",
+					ActivatesOn: time.Now(),
+					ExpiresOn:   time.Now().Add(time.Hour),
+				},
+				Payload: map[string]interface{}{"customer": customer},
+			},
+			PublicKey: &rootKey.PublicKey,
+		}
+	}
+
+	file := filepath.Join(dir, "batch.lic")
+	issued, err := issuedLicenses(cm.Ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skip, err := alreadyIssued(file, rootCert, "testing", request("Bob"), issued["batch"])
+	if skip || err != nil {
+		t.Errorf("expected a missing license to be issued, got %v (%v)", skip, err)
+	}
+
+	req := request("Bob")
+	c, err := cm.IssueLicense(req.Data, req.PublicKey, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = writeContainer(file, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skip, err = alreadyIssued(file, rootCert, "testing", request("Bob"), nil)
+	if skip || err == nil {
+		t.Error("expected a license missing from the ledger to conflict")
+	}
+
+	issued, err = issuedLicenses(cm.Ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skip, err = alreadyIssued(file, rootCert, "testing", request("Bob"), issued["batch"])
+	if !skip || err != nil {
+		t.Errorf("expected a matching license to be skipped, got %v (%v)", skip, err)
+	}
+
+	skip, err = alreadyIssued(file, rootCert, "testing", request("Alice"), issued["batch"])
+	if skip || err == nil {
+		t.Error("expected a license with a different payload to conflict")
+	}
+
+	req = request("Bob")
+	req.PublicKey = otherKey
+	skip, err = alreadyIssued(file, rootCert, "testing", req, issued["batch"])
+	if skip || err == nil {
+		t.Error("expected a license for a different public key to conflict")
+	}
+
+	err = ioutil.WriteFile(file, []byte("corrupt"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skip, err = alreadyIssued(file, rootCert, "testing", request("Bob"), issued["batch"])
+	if skip || err == nil {
+		t.Error("expected an unreadable license to conflict")
+	}
+}
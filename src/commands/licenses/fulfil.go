@@ -0,0 +1,72 @@
+package licenses
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func fulfilCommand() cli.Command {
+	flags := append(shared.PassphraseFlags(), shared.MetadataFlags()...)
+	flags = append(flags, shared.PayloadFlags()...)
+
+	return cli.Command{
+		Name:        "fulfil",
+		Usage:       "issue a license in response to an offline license request",
+		ArgsUsage:   "REQUEST OUTPUT",
+		Description: "This will issue a license for the machine which produced the given license request, for the application named in the request and from the license template it requested unless --template is provided, writing the license container to the output file so that it may be carried back to the machine and installed.",
+		Flags: append(flags,
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.TemplateFlag(),
+			shared.SigningHashFlag("the hash algorithm used to sign the license, one of sha1, sha256 or sha512"),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the license request file and the file to which the license should be written")
+			}
+
+			reqData, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			req, err := license.ParseLicenseRequest(reqData)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("invalid license request: %s", err), 1)
+			}
+
+			template := c.String("template")
+			if template == "" {
+				template = req.Template
+			}
+
+			data, err := shared.LicenseData(c, req.Product, template)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, key, closeKey, err := shared.LicenseIssuer(c, req.Product)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			container, err := cm.FulfilLicenseRequest(req, data, key, c.String("algorithm"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = writeContainer(c.Args()[1], container)
+			if err != nil {
+				return err
+			}
+
+			return printIssued(c, data, c.Args()[1])
+		},
+	}
+}
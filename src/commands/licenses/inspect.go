@@ -0,0 +1,167 @@
+package licenses
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func inspectCommand() cli.Command {
+	return cli.Command{
+		Name:        "inspect",
+		Usage:       "show the structure of a license container",
+		ArgsUsage:   "FILE",
+		Description: "This will show the blocks, signature and certificate chain of a license container. If --decrypt is provided, the license data will be decrypted using this machine's key and shown as well.",
+		Flags: append(shared.KeyFlags(),
+			cli.BoolFlag{
+				Name:  "decrypt",
+				Usage: "decrypt the license data using this machine's private key",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the license file to inspect")
+			}
+
+			data, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			result := &inspection{Blocks: readBlocks(data)}
+
+			container, err := license.ParseContainer(data)
+			if err != nil {
+				if perr := shared.Print(c, result); perr != nil {
+					return perr
+				}
+
+				return cli.NewExitError(fmt.Sprintf("invalid license container: %s", err), 1)
+			}
+
+			result.Signature = &signatureOutput{
+				Algorithm: container.Signature.Algorithm,
+				Size:      len(container.Signature.Data),
+			}
+			result.Encryption = container.Payload.Algorithm
+			result.Certificates = shared.NewCertificateOutputs(container.Certificates)
+
+			if !c.Bool("decrypt") {
+				return shared.Print(c, result)
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if !km.HasKeypair() {
+				return cli.NewExitError("no keypair exists for this machine", 1)
+			}
+
+			privKey, err := km.GetPrivateKey()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			var d license.Data
+			err = container.Payload.Decrypt(&d, privKey)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("could not decrypt license: %s", err), 1)
+			}
+
+			result.data = &d
+			result.License = shared.NewLicenseOutput(&d)
+			return shared.Print(c, result)
+		},
+	}
+}
+
+// inspection describes the structure of a license container.
+type inspection struct {
+	Blocks       []*blockOutput              `json:"blocks"`
+	Signature    *signatureOutput            `json:"signature,omitempty"`
+	Encryption   string                      `json:"encryption,omitempty"`
+	Certificates []*shared.CertificateOutput `json:"certificates,omitempty"`
+	License      *shared.LicenseOutput       `json:"license,omitempty"`
+
+	data *license.Data
+}
+
+type blockOutput struct {
+	Type    string            `json:"type"`
+	Size    int               `json:"size"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type signatureOutput struct {
+	Algorithm string `json:"algorithm"`
+	Size      int    `json:"size"`
+}
+
+func (i *inspection) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, "Blocks:")
+	for _, block := range i.Blocks {
+		headers := []string{}
+		for k, v := range block.Headers {
+			headers = append(headers, fmt.Sprintf("%s=%s", k, v))
+		}
+		sort.Strings(headers)
+
+		fmt.Fprintf(w, "  %-28s %6d bytes  %s\n", block.Type, block.Size, strings.Join(headers, " "))
+	}
+
+	if i.Signature == nil {
+		return
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Signature:   %s (%d bytes)\n", i.Signature.Algorithm, i.Signature.Size)
+	fmt.Fprintf(w, "Encryption:  %s\n", i.Encryption)
+
+	for index, cert := range i.Certificates {
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "Certificate %d:\n", index)
+		cert.WriteTable(w)
+	}
+
+	if i.data != nil {
+		out, err := json.MarshalIndent(i.data, "", "  ")
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, string(out))
+	}
+}
+
+func readBlocks(data []byte) []*blockOutput {
+	blocks := []*blockOutput{}
+
+	rest := data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		blocks = append(blocks, &blockOutput{
+			Type:    block.Type,
+			Size:    len(block.Bytes),
+			Headers: block.Headers,
+		})
+	}
+
+	return blocks
+}
@@ -0,0 +1,98 @@
+package licenses
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func issueCommand() cli.Command {
+	flags := append(shared.PassphraseFlags(), shared.MetadataFlags()...)
+	flags = append(flags, shared.PayloadFlags()...)
+
+	return cli.Command{
+		Name:        "issue",
+		Usage:       "issue a license for a machine",
+		ArgsUsage:   "PUBKEY OUTPUT",
+		Description: "This will issue a license for the machine with the given public key, encrypted so that only that machine may read it and signed by the application's certificate, writing the license container to the output file.",
+		Flags: append(flags,
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.TemplateFlag(),
+			shared.ProductFlag("the ID of the application for which the license should be issued"),
+			shared.SigningHashFlag("the hash algorithm used to sign the license, one of sha1, sha256 or sha512"),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the machine's public key file and the file to which the license should be written")
+			}
+
+			if c.String("product") == "" {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			pubData, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			pubKey, err := license.ParsePublicKey(pubData)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("invalid public key: %s", err), 1)
+			}
+
+			data, err := shared.LicenseData(c, c.String("product"), c.String("template"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, key, closeKey, err := shared.LicenseIssuer(c, c.String("product"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			container, err := cm.IssueLicense(data, pubKey, key, c.String("algorithm"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = writeContainer(c.Args()[1], container)
+			if err != nil {
+				return err
+			}
+
+			return printIssued(c, data, c.Args()[1])
+		},
+	}
+}
+
+// issuedLicense describes a license written by one of the commands which
+// issue licenses, and is presented as its ID in table format.
+type issuedLicense struct {
+	*shared.LicenseOutput
+}
+
+func (l *issuedLicense) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, l.ID)
+}
+
+func printIssued(c *cli.Context, data *license.Data, file string) error {
+	result := &issuedLicense{shared.NewLicenseOutput(data)}
+	result.File = file
+	return shared.Print(c, result)
+}
+
+func writeContainer(file string, container *license.Container) error {
+	data, err := license.EncodeContainer(container)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0644)
+}
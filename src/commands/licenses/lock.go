@@ -0,0 +1,59 @@
+package licenses
+
+import (
+	"errors"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func lockCommand() cli.Command {
+	flags := append(shared.PassphraseFlags(), shared.MetadataFlags()...)
+	flags = append(flags, shared.PayloadFlags()...)
+
+	return cli.Command{
+		Name:        "lock",
+		Usage:       "issue a locked license which is activated using a response code",
+		ArgsUsage:   "OUTPUT",
+		Description: "This will issue a license which is not bound to any machine, writing the locked license container to the output file so that it may be shipped with your application. A single machine may later activate it using the response code produced by the respond command for its activation code. Keep a copy of the locked license, as it is required to produce the response code.",
+		Flags: append(flags,
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.TemplateFlag(),
+			shared.ProductFlag("the ID of the application for which the license should be issued"),
+			shared.SigningHashFlag("the hash algorithm used to sign the license, one of sha1, sha256 or sha512"),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the file to which the license should be written")
+			}
+
+			if c.String("product") == "" {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			data, err := shared.LicenseData(c, c.String("product"), c.String("template"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, key, closeKey, err := shared.LicenseIssuer(c, c.String("product"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			container, err := cm.LockLicense(data, key, c.String("algorithm"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = writeContainer(c.Args()[0], container)
+			if err != nil {
+				return err
+			}
+
+			return printIssued(c, data, c.Args()[0])
+		},
+	}
+}
@@ -0,0 +1,24 @@
+package licenses
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+// Command returns the license command group, which is used to issue and
+// examine license containers.
+func Command() cli.Command {
+	return cli.Command{
+		Name:  "license",
+		Usage: "issue and examine licenses for an application",
+		Subcommands: cli.Commands{
+			issueCommand(),
+			fulfilCommand(),
+			lockCommand(),
+			respondCommand(),
+			batchCommand(),
+			templateCommand(),
+			inspectCommand(),
+			verifyCommand(),
+		},
+	}
+}
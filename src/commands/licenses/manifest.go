@@ -0,0 +1,160 @@
+package licenses
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// manifestEntry describes a single license to be issued by the batch
+// command. Manifests are either a JSON array of entries or a CSV file
+// whose header names the entry's fields, in which case any additional
+// columns are included in the license payload.
+type manifestEntry struct {
+	ID        string                 `json:"id,omitempty"`
+	Customer  string                 `json:"customer,omitempty"`
+	PublicKey string                 `json:"publicKey"`
+	Template  string                 `json:"template,omitempty"`
+	Activates string                 `json:"activates,omitempty"`
+	Expires   string                 `json:"expires,omitempty"`
+	Duration  string                 `json:"duration,omitempty"`
+	Payload   map[string]interface{} `json:"payload,omitempty"`
+}
+
+// readManifest reads the entries of a JSON or CSV manifest, selecting the
+// format using the file's extension.
+func readManifest(file string) ([]*manifestEntry, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.ToLower(filepath.Ext(file)) == ".csv" {
+		return parseCSVManifest(data)
+	}
+
+	entries := []*manifestEntry{}
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+
+	return entries, nil
+}
+
+func parseCSVManifest(data []byte) ([]*manifestEntry, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest: %s", err)
+	}
+
+	entries := []*manifestEntry{}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest: %s", err)
+		}
+
+		entry := &manifestEntry{
+			Payload: map[string]interface{}{},
+		}
+
+		for i, column := range header {
+			value := strings.TrimSpace(record[i])
+			switch column {
+			case "id":
+				entry.ID = value
+			case "customer":
+				entry.Customer = value
+			case "publicKey":
+				entry.PublicKey = value
+			case "template":
+				entry.Template = value
+			case "activates":
+				entry.Activates = value
+			case "expires":
+				entry.Expires = value
+			case "duration":
+				entry.Duration = value
+			default:
+				if value != "" {
+					entry.Payload[column] = shared.PayloadValue(value)
+				}
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// publicKey loads the entry's public key, which may be provided inline in
+// PEM form or as the path to a file, relative to the manifest's folder.
+func (e *manifestEntry) publicKey(dir string) (*rsa.PublicKey, error) {
+	if e.PublicKey == "" {
+		return nil, errors.New("no public key was provided")
+	}
+
+	data := []byte(e.PublicKey)
+	if !strings.HasPrefix(e.PublicKey, "-----BEGIN") {
+		file := e.PublicKey
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+
+		var err error
+		data, err = ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return license.ParsePublicKey(data)
+}
+
+// licenseID returns the ID of the entry's license. Entries without an
+// explicit ID are given one derived from their contents, including their
+// payload, ensuring that the same license is identified each time the
+// manifest is processed and that a changed entry is issued a new license.
+func (e *manifestEntry) licenseID(productID string, pubKey *rsa.PublicKey) (string, error) {
+	if e.ID != "" {
+		return e.ID, nil
+	}
+
+	fingerprint, err := license.KeyFingerprint(pubKey)
+	if err != nil {
+		return "", err
+	}
+
+	payloadDigest, err := license.PayloadDigest(e.Payload)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, field := range []string{productID, e.Customer, fingerprint, e.Template, e.Activates, e.Expires, e.Duration, hex.EncodeToString(payloadDigest)} {
+		fmt.Fprintf(hash, "%d:%s", len(field), field)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)[:16]), nil
+}
@@ -0,0 +1,49 @@
+package licenses
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestManifestLicenseID(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &manifestEntry{
+		Customer: "Acme",
+		Template: "standard",
+		Payload:  map[string]interface{}{"seats": "5"},
+	}
+
+	id, err := entry.licenseID("testing", &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := entry.licenseID("testing", &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again != id {
+		t.Errorf("expected the same entry to be given the same ID, got %s and %s", id, again)
+	}
+
+	entry.Payload["seats"] = "10"
+	changed, err := entry.licenseID("testing", &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed == id {
+		t.Error("expected an entry whose payload changed to be given a new ID")
+	}
+
+	entry.ID = "explicit"
+	if explicit, _ := entry.licenseID("testing", &key.PublicKey); explicit != "explicit" {
+		t.Errorf("expected an entry's explicit ID to be used, got %s", explicit)
+	}
+}
@@ -0,0 +1,72 @@
+package licenses
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func respondCommand() cli.Command {
+	return cli.Command{
+		Name:        "respond",
+		Usage:       "produce the response code which activates a locked license",
+		ArgsUsage:   "LICENSE CODE",
+		Description: "This will print the response code which activates the given locked license for the machine with the given activation code. The response code should be read back to the customer, who will enter it to activate the license.",
+		Flags: append(shared.PassphraseFlags(),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.ProductFlag("the ID of the application for which the license was issued"),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the locked license file and the machine's activation code")
+			}
+
+			if c.String("product") == "" {
+				return errors.New("expected you to provide the ID of the application")
+			}
+
+			data, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			container, err := license.ParseContainer(data)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("invalid license container: %s", err), 1)
+			}
+
+			code, err := license.ParseActivationCode(c.Args()[1])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			cm, key, closeKey, err := shared.LicenseIssuer(c, c.String("product"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			defer closeKey()
+
+			response, err := cm.RespondToActivation(container, code, key)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, activationResponse{Response: response})
+		},
+	}
+}
+
+// activationResponse holds the response code which unlocks a license.
+type activationResponse struct {
+	Response string `json:"response"`
+}
+
+func (r activationResponse) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, r.Response)
+}
@@ -0,0 +1,246 @@
+package licenses
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func templateCommand() cli.Command {
+	return cli.Command{
+		Name:  "template",
+		Usage: "manage the named license templates of an application",
+		Subcommands: cli.Commands{
+			templateListCommand(),
+			templateShowCommand(),
+			templateSetCommand(),
+			templateDeleteCommand(),
+		},
+	}
+}
+
+func productFlag() cli.Flag {
+	return shared.ProductFlag("the ID of the application whose templates should be managed")
+}
+
+// templateStore opens the template store of the application selected by
+// productFlag.
+func templateStore(c *cli.Context) (*license.FileTemplateStore, error) {
+	if c.String("product") == "" {
+		return nil, errors.New("expected you to provide the ID of the application")
+	}
+
+	product, err := shared.LoadProduct(c.GlobalString("licensePath"), c.String("product"))
+	if err != nil {
+		return nil, err
+	}
+
+	return shared.LicenseTemplates(c.GlobalString("licensePath"), product.ID), nil
+}
+
+func templateListCommand() cli.Command {
+	return cli.Command{
+		Name:  "list",
+		Usage: "list the license templates of an application",
+		Flags: []cli.Flag{
+			productFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			store, err := templateStore(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			templates, err := store.List()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, templateList(templates))
+		},
+	}
+}
+
+func templateShowCommand() cli.Command {
+	return cli.Command{
+		Name:      "show",
+		Usage:     "show the definition of a license template",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			productFlag(),
+			cli.BoolFlag{
+				Name:  "resolved",
+				Usage: "show the template merged with every template it extends",
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the name of the template")
+			}
+
+			store, err := templateStore(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			var t *license.LicenseTemplate
+			if c.Bool("resolved") {
+				t, err = license.ResolveTemplate(store, c.Args()[0])
+			} else {
+				t, err = store.Get(c.Args()[0])
+			}
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, &templateOutput{t})
+		},
+	}
+}
+
+// templateList is presented as a table of template names in table format.
+type templateList []*license.LicenseTemplate
+
+func (l templateList) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "%-20s %-20s %-10s\n", "Name", "Extends", "Validity")
+
+	for _, t := range l {
+		validity := "-"
+		if t.ValidityDays > 0 {
+			validity = fmt.Sprintf("%d days", t.ValidityDays)
+		}
+
+		fmt.Fprintf(w, "%-20s %-20s %-10s\n", t.Name, t.Extends, validity)
+	}
+}
+
+// templateOutput presents a template as its JSON definition, even in
+// table format.
+type templateOutput struct {
+	*license.LicenseTemplate
+}
+
+func (t *templateOutput) WriteTable(w io.Writer) {
+	data, err := json.MarshalIndent(t.LicenseTemplate, "", "  ")
+	if err != nil {
+		return
+	}
+
+	fmt.Fprintln(w, string(data))
+}
+
+func templateSetCommand() cli.Command {
+	return cli.Command{
+		Name:        "set",
+		Usage:       "create or update a license template",
+		ArgsUsage:   "NAME",
+		Description: "This will create a license template, or update the properties of an existing template which are provided. Payload entries are merged into the template's existing payload.",
+		Flags: append(shared.PayloadFlags(),
+			productFlag(),
+			cli.StringFlag{
+				Name:  "extends",
+				Usage: "the `name` of the template from which this template inherits its defaults",
+			},
+			cli.IntFlag{
+				Name:  "validityDays",
+				Usage: "the number of `days` for which licenses issued from the template are valid",
+			},
+			cli.StringFlag{
+				Name:  "pack",
+				Usage: "a JSON `file` describing the license templates the license holder may issue",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the name of the template")
+			}
+
+			store, err := templateStore(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			t, err := store.Get(c.Args()[0])
+			if err == license.ErrTemplateNotFound {
+				t = &license.LicenseTemplate{
+					Name: c.Args()[0],
+				}
+			} else if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if c.IsSet("extends") {
+				t.Extends = c.String("extends")
+			}
+
+			if c.IsSet("validityDays") {
+				t.ValidityDays = c.Int("validityDays")
+			}
+
+			payload, err := shared.LicensePayload(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			for k, v := range payload {
+				if t.Payload == nil {
+					t.Payload = map[string]interface{}{}
+				}
+
+				t.Payload[k] = v
+			}
+
+			if packFile := c.String("pack"); packFile != "" {
+				t.Pack, err = shared.ReadPack(packFile)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+			}
+
+			err = store.Put(t)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if _, err := license.ResolveTemplate(store, t.Name); err != nil {
+				fmt.Fprintf(os.Stderr, "warning: the template cannot be used yet: %s\n", err)
+			}
+
+			return nil
+		},
+	}
+}
+
+func templateDeleteCommand() cli.Command {
+	return cli.Command{
+		Name:      "delete",
+		Usage:     "delete a license template",
+		ArgsUsage: "NAME",
+		Flags: []cli.Flag{
+			productFlag(),
+		},
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the name of the template")
+			}
+
+			store, err := templateStore(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = store.Delete(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
@@ -0,0 +1,188 @@
+package licenses
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// The exit codes used by the verify command to identify the reason a
+// license was rejected.
+const (
+	exitInvalidUsage     = 1
+	exitMalformed        = 2
+	exitUntrusted        = 3
+	exitInvalidSignature = 4
+	exitWrongProduct     = 5
+	exitUndecryptable    = 6
+	exitNotActivated     = 7
+	exitExpired          = 8
+)
+
+func verifyCommand() cli.Command {
+	return cli.Command{
+		Name:      "verify",
+		Usage:     "validate a license container against an application's root certificate",
+		ArgsUsage: "FILE",
+		Description: `This will validate a license container's certificate chain and signature against the
+   root certificate of an application, and that it was signed by a certificate for that application.
+   If --decrypt is provided, the license data is decrypted using this machine's key and its validity
+   period checked as well.
+
+   The command exits with one of the following codes:
+     0  the license is valid
+     1  the command was used incorrectly or a file could not be read
+     2  the license container is malformed
+     3  the certificate chain is not trusted by the root certificate, violates its constraints or
+        does not permit the license
+     4  the signature does not match the license data
+     5  the license was issued, or signed by a certificate, for another application
+     6  the license could not be decrypted using this machine's key, or no key is available
+     7  the license has not yet activated
+     8  the license has expired`,
+		Flags: append(shared.KeyFlags(),
+			shared.CertPathFlag(),
+			shared.RootFlag(),
+			cli.BoolFlag{
+				Name:  "decrypt",
+				Usage: "decrypt the license data using this machine's private key and check its validity period",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return cli.NewExitError("expected you to provide the license file to verify", exitInvalidUsage)
+			}
+
+			root, err := shared.TrustedRoot(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitInvalidUsage)
+			}
+
+			data, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), exitInvalidUsage)
+			}
+
+			result := verifyContainer(c, root, data)
+			if !result.Valid && shared.OutputFormat(c) == shared.OutputTable {
+				return cli.NewExitError(result.Reason, result.Code)
+			}
+
+			err = shared.Print(c, result)
+			if err != nil {
+				return err
+			}
+
+			if !result.Valid {
+				return cli.NewExitError(result.Reason, result.Code)
+			}
+
+			return nil
+		},
+	}
+}
+
+// verifyContainer validates a license container, describing the reason it
+// was rejected, if any, using the verify command's exit codes.
+func verifyContainer(c *cli.Context, root *x509.Certificate, data []byte) *shared.ValidationOutput {
+	var decrypted *shared.LicenseOutput
+	rejected := func(reason string, code int) *shared.ValidationOutput {
+		return &shared.ValidationOutput{Reason: reason, Code: code, License: decrypted}
+	}
+
+	container, err := license.ParseContainer(data)
+	if err != nil {
+		return rejected(fmt.Sprintf("invalid license container: %s", err), exitMalformed)
+	}
+
+	if len(container.Certificates) == 0 || !container.Certificates[0].Equal(root) {
+		return rejected(license.ErrUntrustedRoot.Error(), exitUntrusted)
+	}
+
+	err = license.VerifyChain(container.Certificates, time.Now())
+	if err != nil {
+		return rejected(err.Error(), exitUntrusted)
+	}
+
+	// Without an explicit product, licenses must have been issued for the
+	// product the root certificate is bound to.
+	product := c.String("product")
+	if product == license.DefaultKeyProduct {
+		product = ""
+	}
+
+	if product != "" {
+		_, err = container.IsValidFor(root, product)
+	} else {
+		_, err = container.IsValid(root)
+	}
+
+	if err != nil {
+		return rejected(err.Error(), validationExitCode(err, exitMalformed))
+	}
+
+	if c.Bool("decrypt") {
+		km, err := shared.KeyManager(c)
+		if err != nil {
+			return rejected(err.Error(), exitUndecryptable)
+		}
+
+		if !km.HasKeypair() {
+			return rejected("no keypair exists for this machine", exitUndecryptable)
+		}
+
+		privKey, err := km.GetPrivateKey()
+		if err != nil {
+			return rejected(err.Error(), exitUndecryptable)
+		}
+
+		var d license.Data
+		err = container.Payload.Decrypt(&d, privKey)
+		if err != nil {
+			return rejected(fmt.Sprintf("could not decrypt license: %s", err), exitUndecryptable)
+		}
+		decrypted = shared.NewLicenseOutput(&d)
+
+		if product != "" {
+			_, err = container.LicenseFor(privKey, root, product)
+		} else {
+			_, err = container.License(privKey, root)
+		}
+
+		if err != nil {
+			return rejected(err.Error(), validationExitCode(err, exitUntrusted))
+		}
+
+		_, err = d.IsValid()
+		switch err {
+		case nil:
+		case license.ErrNotActivated:
+			return rejected(err.Error(), exitNotActivated)
+		case license.ErrExpired:
+			return rejected(err.Error(), exitExpired)
+		default:
+			return rejected(err.Error(), exitMalformed)
+		}
+	}
+
+	return &shared.ValidationOutput{Valid: true, License: decrypted}
+}
+
+// validationExitCode returns the exit code identifying a validation error,
+// using the given code for errors without a more specific one.
+func validationExitCode(err error, code int) int {
+	if err == license.ErrInvalidSignature {
+		return exitInvalidSignature
+	}
+
+	if _, ok := err.(*license.WrongProductError); ok {
+		return exitWrongProduct
+	}
+
+	return code
+}
@@ -0,0 +1,132 @@
+package licenses
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func TestVerifyExitCodes(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	km := license.NewKeyManager([]byte("test"))
+	km.Path = dir
+	km.KeySize = 1024
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm := license.NewCertManager(&license.Product{ID: "testing", Name: "Testing", Organization: "Lithium Testing"})
+	cm.Path = dir
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootFile := filepath.Join(dir, "root.pem")
+	rootData, err := license.EncodeChain(&license.Chain{Certificates: []*x509.Certificate{rootCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(rootFile, rootData, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeLicense := func(name string, tamper func(c *license.Container)) string {
+		c := &license.Container{Certificates: []*x509.Certificate{rootCert}}
+		err := c.SetLicense(&license.Data{
+			Meta: &license.Metadata{
+				ID:          name,
+				Product:     "testing",
+				ActivatesOn: time.Now(),
+				ExpiresOn:   time.Now().Add(time.Hour),
+			},
+			Payload: map[string]interface{}{},
+		}, &rootKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Sign(rootKey, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tamper != nil {
+			tamper(c)
+		}
+
+		data, err := license.EncodeContainer(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file := filepath.Join(dir, name+".license")
+		err = ioutil.WriteFile(file, data, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return file
+	}
+
+	valid := writeLicense("valid", nil)
+	unsupportedHash := writeLicense("hash", func(c *license.Container) { c.Signature.Algorithm = "md4" })
+	badSignature := writeLicense("signature", func(c *license.Container) { c.Signature.Data[0] ^= 0xff })
+
+	exitCode := 0
+	cli.OsExiter = func(code int) { exitCode = code }
+	cli.ErrWriter = ioutil.Discard
+	defer func() {
+		cli.OsExiter = os.Exit
+		cli.ErrWriter = os.Stderr
+	}()
+
+	verify := func(args ...string) int {
+		exitCode = 0
+
+		app := cli.NewApp()
+		app.Writer = ioutil.Discard
+		app.Commands = []cli.Command{Command()}
+		app.Flags = []cli.Flag{
+			shared.LicensePathFlag(),
+			shared.OutputFlag(),
+		}
+
+		app.Run(append([]string{"litmus", "--licensePath", dir, "license", "verify", "--root", rootFile}, args...))
+		return exitCode
+	}
+
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"a valid license", []string{valid}, 0},
+		{"an unsupported hash", []string{unsupportedHash}, exitMalformed},
+		{"an invalid signature", []string{badSignature}, exitInvalidSignature},
+		{"another product", []string{"--product", "other", valid}, exitWrongProduct},
+	}
+
+	for _, tc := range cases {
+		if code := verify(tc.args...); code != tc.code {
+			t.Errorf("expected %s to exit with %d, got %d", tc.name, tc.code, code)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func activateCommand() cli.Command {
+	return cli.Command{
+		Name:        "activate",
+		Usage:       "activate a locked license using a response code",
+		ArgsUsage:   "LICENSE RESPONSE",
+		Description: "This will unlock a locked license using the response code provided by your vendor for this machine's activation code, validate it against the application's root certificate and install it for this machine's keypair.",
+		Flags: append(shared.KeyFlags(),
+			shared.CertPathFlag(),
+			shared.RootFlag(),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 2 {
+				return errors.New("expected you to provide the locked license file and the response code")
+			}
+
+			root, err := shared.TrustedRoot(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			locked, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := km.ActivateLicense(locked, c.Args()[1], root)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("the license could not be activated: %s", err), 1)
+			}
+
+			return shared.Print(c, &installedLicense{shared.NewLicenseOutput(data), "Activated"})
+		},
+	}
+}
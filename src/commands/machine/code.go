@@ -0,0 +1,41 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func codeCommand() cli.Command {
+	return cli.Command{
+		Name:        "code",
+		Usage:       "print this machine's activation code",
+		Description: "This will print a short activation code identifying this machine and the application selected by --product. The code may be read to your vendor over the phone in exchange for a response code which activates a locked license.",
+		Flags:       shared.KeyFlags(),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			code, err := km.ActivationCode()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, activationCode{Code: code.String()})
+		},
+	}
+}
+
+// activationCode holds the code which a machine reads to its vendor to
+// activate a locked license.
+type activationCode struct {
+	Code string `json:"code"`
+}
+
+func (a activationCode) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, a.Code)
+}
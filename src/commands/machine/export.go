@@ -0,0 +1,45 @@
+package machine
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func exportCommand() cli.Command {
+	return cli.Command{
+		Name:        "export",
+		Usage:       "export this machine's keys and licenses to a bundle",
+		ArgsUsage:   "FILE",
+		Description: "This will write a passphrase protected bundle containing this machine's keypair and all of its installed licenses, which may be imported on new hardware. The passphrase is requested interactively unless it is provided with --passphrase or --passphraseFd.",
+		Flags:       append(shared.KeyFlags(), shared.BundlePassphraseFlags()...),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the file to which the bundle should be written")
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if !km.HasKeypair() {
+				return cli.NewExitError("no keypair exists for this machine", 1)
+			}
+
+			passphrase, err := shared.BundlePassphrase(c, "Enter passphrase for the bundle: ", true)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			bundle, err := km.ExportBundle(passphrase)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return ioutil.WriteFile(c.Args()[0], bundle, 0600)
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func fingerprintCommand() cli.Command {
+	return cli.Command{
+		Name:        "fingerprint",
+		Usage:       "print the fingerprint of this machine's public key",
+		Description: "This will print a short fingerprint of this machine's public key which may be read to your vendor to confirm that they hold the correct key.",
+		Flags: append(shared.KeyFlags(),
+			cli.BoolFlag{
+				Name:  "full",
+				Usage: "print the full SHA-256 fingerprint of the key",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if !km.HasKeypair() {
+				return cli.NewExitError("no keypair exists for this machine", 1)
+			}
+
+			pub, err := km.GetPublicKey()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			fingerprint := license.ShortFingerprint
+			if c.Bool("full") {
+				fingerprint = license.KeyFingerprint
+			}
+
+			fp, err := fingerprint(pub)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, keyFingerprint{Fingerprint: fp})
+		},
+	}
+}
+
+// keyFingerprint holds the fingerprint of a machine's public key.
+type keyFingerprint struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (f keyFingerprint) WriteTable(w io.Writer) {
+	fmt.Fprintln(w, f.Fingerprint)
+}
@@ -0,0 +1,55 @@
+package machine
+
+import (
+	"errors"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func importCommand() cli.Command {
+	return cli.Command{
+		Name:        "import",
+		Usage:       "import a bundle of keys and licenses exported from another machine",
+		ArgsUsage:   "FILE",
+		Description: "This will install the keypair and licenses from a bundle created by the export command, protecting the key with this machine's machine code. The bundle's passphrase is requested interactively unless it is provided with --passphrase or --passphraseFd.",
+		Flags: append(append(shared.KeyFlags(), shared.BundlePassphraseFlags()...),
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "replace this machine's existing keypair",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the bundle file which should be imported")
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if km.HasKeypair() && !c.Bool("force") {
+				return cli.NewExitError("a keypair already exists for this machine, use --force to replace it", 1)
+			}
+
+			bundle, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			passphrase, err := shared.BundlePassphrase(c, "Enter passphrase for the bundle: ", false)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			err = km.ImportBundle(bundle, passphrase)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
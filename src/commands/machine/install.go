@@ -0,0 +1,62 @@
+package machine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func installCommand() cli.Command {
+	return cli.Command{
+		Name:        "install",
+		Usage:       "install a license issued for this machine",
+		ArgsUsage:   "FILE",
+		Description: "This will validate a license container, such as one issued in response to an offline license request, against the application's root certificate and install it for this machine's keypair.",
+		Flags: append(shared.KeyFlags(),
+			shared.CertPathFlag(),
+			shared.RootFlag(),
+		),
+		Action: func(c *cli.Context) error {
+			if c.NArg() < 1 {
+				return errors.New("expected you to provide the license file which should be installed")
+			}
+
+			root, err := shared.TrustedRoot(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			response, err := ioutil.ReadFile(c.Args()[0])
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := km.InstallLicenseResponse(response, root)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("the license could not be installed: %s", err), 1)
+			}
+
+			return shared.Print(c, &installedLicense{shared.NewLicenseOutput(data), "Installed"})
+		},
+	}
+}
+
+// installedLicense describes a license installed on this machine.
+type installedLicense struct {
+	*shared.LicenseOutput
+
+	action string
+}
+
+func (l *installedLicense) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "%s license %s\n", l.action, l.ID)
+}
@@ -0,0 +1,26 @@
+package machine
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+// Command returns the machine command group, which is used to manage the
+// local machine's keys and licenses.
+func Command() cli.Command {
+	return cli.Command{
+		Name:  "machine",
+		Usage: "manage the keys and licenses installed on this machine",
+		Subcommands: cli.Commands{
+			pubkeyCommand(),
+			fingerprintCommand(),
+			statusCommand(),
+			requestCommand(),
+			installCommand(),
+			codeCommand(),
+			activateCommand(),
+			resetCommand(),
+			exportCommand(),
+			importCommand(),
+		},
+	}
+}
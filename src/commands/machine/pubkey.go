@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func pubkeyCommand() cli.Command {
+	return cli.Command{
+		Name:        "pubkey",
+		Usage:       "print or export this machine's public key",
+		ArgsUsage:   "[FILE]",
+		Description: "This will print this machine's public key, creating a keypair if one does not yet exist, or write it to FILE if one is provided. The public key should be sent to your vendor so that they can issue licenses for this machine.",
+		Flags:       shared.KeyFlags(),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			_, err = km.GetPublicKey()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := ioutil.ReadFile(km.PublicKeyPath())
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if c.NArg() > 0 {
+				return ioutil.WriteFile(c.Args()[0], data, 0644)
+			}
+
+			return shared.Print(c, publicKey{PublicKey: string(data)})
+		},
+	}
+}
+
+// publicKey holds a machine's PEM encoded public key.
+type publicKey struct {
+	PublicKey string `json:"publicKey"`
+}
+
+func (k publicKey) WriteTable(w io.Writer) {
+	fmt.Fprint(w, k.PublicKey)
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/codegangsta/cli"
+)
+
+func requestCommand() cli.Command {
+	return cli.Command{
+		Name:        "request",
+		Usage:       "create an offline license request for this machine",
+		ArgsUsage:   "[FILE]",
+		Description: "This will print a signed license request for this machine and the application selected by --product, or write it to FILE if one is provided. The request should be carried to your vendor, who can fulfil it without this machine needing network access.",
+		Flags: append(shared.KeyFlags(),
+			cli.StringFlag{
+				Name:  "template",
+				Usage: "the `name` of the license template you wish to request",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			data, err := km.CreateLicenseRequest(c.String("template"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if c.NArg() > 0 {
+				return ioutil.WriteFile(c.Args()[0], data, 0644)
+			}
+
+			return shared.Print(c, licenseRequest{Request: string(data)})
+		},
+	}
+}
+
+// licenseRequest holds a PEM encoded offline license request.
+type licenseRequest struct {
+	Request string `json:"request"`
+}
+
+func (r licenseRequest) WriteTable(w io.Writer) {
+	fmt.Fprint(w, r.Request)
+}
@@ -0,0 +1,69 @@
+package machine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func resetCommand() cli.Command {
+	return cli.Command{
+		Name:        "reset",
+		Usage:       "replace this machine's keypair",
+		Description: "This will generate a new keypair for this machine. Any licenses issued for the previous keypair will no longer be usable and must be reissued.",
+		Flags: append(shared.KeyFlags(),
+			cli.BoolFlag{
+				Name:  "yes",
+				Usage: "replace the keypair without asking for confirmation",
+			},
+		),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			if km.HasKeypair() && !c.Bool("yes") {
+				fmt.Fprint(os.Stderr, "Replacing this machine's keypair will invalidate all of its licenses. Continue? [y/N] ")
+
+				answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+				answer = strings.ToLower(strings.TrimSpace(answer))
+				if answer != "y" && answer != "yes" {
+					return cli.NewExitError("the keypair was not replaced", 1)
+				}
+			}
+
+			err = km.ResetKeypair()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			pub, err := km.GetPublicKey()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			fingerprint, err := license.ShortFingerprint(pub)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return shared.Print(c, newKeypair{Fingerprint: fingerprint})
+		},
+	}
+}
+
+// newKeypair describes the keypair created by the reset command.
+type newKeypair struct {
+	Fingerprint string `json:"fingerprint"`
+}
+
+func (k newKeypair) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "A new keypair was created with the fingerprint %s\n", k.Fingerprint)
+}
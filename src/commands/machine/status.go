@@ -0,0 +1,135 @@
+package machine
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+func statusCommand() cli.Command {
+	return cli.Command{
+		Name:        "status",
+		Usage:       "show the keys and licenses installed on this machine",
+		Description: "This will show whether a keypair exists for the selected product and profile, along with the licenses which have been installed for it.",
+		Flags:       shared.KeyFlags(),
+		Action: func(c *cli.Context) error {
+			km, err := shared.KeyManager(c)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			result := &machineStatus{
+				Product:    km.Product,
+				Profile:    km.Profile,
+				PrivateKey: km.PrivateKeyPath(),
+				PublicKey:  km.PublicKeyPath(),
+			}
+
+			if !km.HasKeypair() {
+				return shared.Print(c, result)
+			}
+
+			pub, err := km.GetPublicKey()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			result.Fingerprint, err = license.ShortFingerprint(pub)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			licenses, err := km.InstalledLicenses()
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			result.LicensesPath = km.LicensesPath()
+			result.Licenses = []*shared.LicenseOutput{}
+
+			ids := []string{}
+			for id := range licenses {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+
+			privKey, err := km.GetPrivateKey()
+			if err != nil {
+				result.KeyError = fmt.Sprintf("could not unlock the private key: %s", err)
+				privKey = nil
+			}
+
+			for _, id := range ids {
+				if privKey == nil {
+					result.Licenses = append(result.Licenses, &shared.LicenseOutput{ID: id, Status: shared.LicenseUnknown})
+					continue
+				}
+
+				result.Licenses = append(result.Licenses, describeLicense(id, licenses[id], privKey))
+			}
+
+			return shared.Print(c, result)
+		},
+	}
+}
+
+// machineStatus describes the keypair and licenses installed on a machine.
+type machineStatus struct {
+	Product      string                  `json:"product"`
+	Profile      string                  `json:"profile"`
+	PrivateKey   string                  `json:"privateKey"`
+	PublicKey    string                  `json:"publicKey"`
+	Fingerprint  string                  `json:"fingerprint,omitempty"`
+	KeyError     string                  `json:"keyError,omitempty"`
+	LicensesPath string                  `json:"licensesPath,omitempty"`
+	Licenses     []*shared.LicenseOutput `json:"licenses,omitempty"`
+}
+
+func (s *machineStatus) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "Product:     %s\n", s.Product)
+	fmt.Fprintf(w, "Profile:     %s\n", s.Profile)
+	fmt.Fprintf(w, "Private Key: %s\n", s.PrivateKey)
+	fmt.Fprintf(w, "Public Key:  %s\n", s.PublicKey)
+
+	if s.Fingerprint == "" {
+		fmt.Fprintln(w, "Keypair:     not created")
+		return
+	}
+
+	fmt.Fprintf(w, "Fingerprint: %s\n", s.Fingerprint)
+	fmt.Fprintf(w, "Licenses:    %d installed in %s\n", len(s.Licenses), s.LicensesPath)
+
+	if s.KeyError != "" {
+		fmt.Fprintf(w, "  %s\n", s.KeyError)
+	}
+
+	for _, l := range s.Licenses {
+		if l.Status == shared.LicenseUnknown {
+			fmt.Fprintf(w, "  %s\n", l.ID)
+			continue
+		}
+
+		fmt.Fprint(w, "  ")
+		l.WriteTable(w)
+	}
+}
+
+func describeLicense(id string, data []byte, privKey *rsa.PrivateKey) *shared.LicenseOutput {
+	container, err := license.ParseContainer(data)
+	if err != nil {
+		return &shared.LicenseOutput{ID: id, Status: shared.LicenseInvalid, Error: err.Error()}
+	}
+
+	var d license.Data
+	err = container.Payload.Decrypt(&d, privKey)
+	if err != nil || d.Meta == nil {
+		return &shared.LicenseOutput{ID: id, Status: shared.LicenseInvalid, Error: "could not be decrypted"}
+	}
+
+	return shared.NewLicenseOutput(&d)
+}
@@ -0,0 +1,193 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/server"
+	"github.com/codegangsta/cli"
+)
+
+// Command returns the serve command, which runs an HTTP license server.
+func Command() cli.Command {
+	return cli.Command{
+		Name:  "serve",
+		Usage: "run a license server which issues licenses over HTTP",
+		Description: `This will run an HTTP server which issues licenses for the selected applications in response
+   to the license requests produced by machines, posted to /v1/licenses. Requests may name one of the
+   application's license templates, from which the license is issued, and are subject to the policy
+   described by the --template, --defaultTemplate, --duration and --maxClockSkew options. Requests
+   are not authenticated, so only the templates listed by --template, or else the --defaultTemplate
+   alone, may be requested and one of these must be provided.
+
+   When --seats is provided, machines may also check out floating licenses by posting their requests
+   to /v1/seats. These expire after --lease and are renewed or released by posting to
+   /v1/seats/<id>/renew and /v1/seats/<id>/release.`,
+		Flags: append(shared.PassphraseFlags(),
+			shared.CertPathFlag(),
+			shared.ShareFlag(),
+			shared.SigningHashFlag("the hash algorithm used to sign licenses, one of sha1, sha256 or sha512"),
+			cli.StringSliceFlag{
+				Name:   "product",
+				EnvVar: "LITHIUM_PRODUCT",
+				Usage:  "the ID of an application for which licenses should be issued, may be repeated",
+			},
+			cli.StringFlag{
+				Name:   "listen",
+				EnvVar: "LITHIUM_LISTEN",
+				Usage:  "the `address` on which the server should listen",
+				Value:  ":8080",
+			},
+			cli.StringFlag{
+				Name:   "tlsCert",
+				EnvVar: "LITHIUM_TLS_CERT",
+				Usage:  "a `file` containing the certificate with which the server should serve TLS",
+			},
+			cli.StringFlag{
+				Name:   "tlsKey",
+				EnvVar: "LITHIUM_TLS_KEY",
+				Usage:  "a `file` containing the private key matching --tlsCert",
+			},
+			cli.StringSliceFlag{
+				Name:  "template",
+				Usage: "the `name` of a license template which machines may request, may be repeated, defaults to only --defaultTemplate",
+			},
+			cli.StringFlag{
+				Name:  "defaultTemplate",
+				Usage: "the `name` of the license template used for requests which do not name one",
+			},
+			cli.DurationFlag{
+				Name:  "duration",
+				Usage: "the `duration` for which licenses are valid if their template does not specify one",
+				Value: server.DefaultValidity,
+			},
+			cli.DurationFlag{
+				Name:  "maxClockSkew",
+				Usage: "the greatest `duration` by which a machine's clock may differ from the server's, 0 to disable",
+				Value: 5 * time.Minute,
+			},
+			cli.IntFlag{
+				Name:  "seats",
+				Usage: "the `number` of floating licenses which may be checked out for each application, 0 to disable",
+			},
+			cli.DurationFlag{
+				Name:  "lease",
+				Usage: "the `duration` for which floating licenses are checked out before they must be renewed",
+				Value: 5 * time.Minute,
+			},
+		),
+		Action: func(c *cli.Context) error {
+			products := c.StringSlice("product")
+			if len(products) == 0 && shared.Settings().Product != "" {
+				products = []string{shared.Settings().Product}
+			}
+
+			if len(products) == 0 {
+				return cli.NewExitError("expected you to provide the ID of at least one application", 1)
+			}
+
+			if len(c.StringSlice("template")) == 0 && c.String("defaultTemplate") == "" {
+				return cli.NewExitError("expected --template or --defaultTemplate to name the license templates which machines may request", 1)
+			}
+
+			if c.Int("seats") < 0 || (c.Int("seats") > 0 && c.Duration("lease") <= 0) {
+				return cli.NewExitError("expected --seats to be positive and --lease to be provided", 1)
+			}
+
+			if (c.String("tlsCert") == "") != (c.String("tlsKey") == "") {
+				return cli.NewExitError("expected both --tlsCert and --tlsKey to be provided", 1)
+			}
+
+			s := server.New()
+			s.Log = log.New(os.Stderr, "", log.LstdFlags)
+
+			for _, id := range products {
+				cm, key, closeKey, err := shared.LicenseIssuer(c, id)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				defer closeKey()
+
+				issuer := &server.Issuer{
+					CertManager: cm,
+					Key:         key,
+					Algorithm:   c.String("algorithm"),
+					Policy: &server.TemplatePolicy{
+						Templates:       shared.LicenseTemplates(c.GlobalString("licensePath"), cm.Product.ID),
+						Allowed:         c.StringSlice("template"),
+						DefaultTemplate: c.String("defaultTemplate"),
+						Validity:        c.Duration("duration"),
+						MaxClockSkew:    c.Duration("maxClockSkew"),
+					},
+				}
+
+				if c.Int("seats") > 0 {
+					issuer.Seats = license.NewSeatPool(c.Int("seats"), c.Duration("lease"))
+				}
+
+				err = s.AddIssuer(issuer)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+			}
+
+			err := listen(s, c.String("listen"), c.String("tlsCert"), c.String("tlsKey"))
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+
+			return nil
+		},
+	}
+}
+
+// listen serves requests until the process is interrupted, at which point
+// the server is shut down once its outstanding requests have completed,
+// waiting no more than 30 seconds for them to do so.
+func listen(s *server.Server, addr, certFile, keyFile string) error {
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: s,
+	}
+
+	stopped := make(chan error, 1)
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		s.Log.Printf("shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		stopped <- srv.Shutdown(ctx)
+	}()
+
+	s.Log.Printf("listening on %s", addr)
+
+	var err error
+	if certFile != "" {
+		err = srv.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	if err == http.ErrServerClosed {
+		return <-stopped
+	}
+
+	if err == nil {
+		return errors.New("the server stopped unexpectedly")
+	}
+
+	return err
+}
@@ -0,0 +1,179 @@
+package shared
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// LicensePathFlag returns the global flag used to select the folder in
+// which products, templates, certificates and signing keys are stored.
+func LicensePathFlag() cli.Flag {
+	path := Settings().LicensePath
+	if path == "" {
+		cwd, err := filepath.Abs("./")
+		if err != nil {
+			cwd = os.ExpandEnv("$HOME")
+		}
+
+		path = filepath.Join(cwd, ".lithium")
+	}
+
+	return cli.StringFlag{
+		Name:   "licensePath",
+		EnvVar: "LITHIUM_LICENSE_PATH",
+		Usage:  "the `path` under which licenses are stored",
+		Value:  path,
+	}
+}
+
+// CertPathFlag returns the flag used to select the folder in which a
+// product's certificates and signing keys are stored, if they are not kept
+// alongside its definition in the folder selected by LicensePathFlag.
+func CertPathFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:  "path",
+		Usage: "the folder within which your certificates and signing keys are stored, defaults to --licensePath",
+	}
+}
+
+// CertPath retrieves the folder selected by CertPathFlag.
+func CertPath(c *cli.Context) string {
+	if path := c.String("path"); path != "" {
+		return path
+	}
+
+	return c.GlobalString("licensePath")
+}
+
+// SigningHashFlag returns the flag used to select the hash algorithm with
+// which licenses are signed.
+func SigningHashFlag(usage string) cli.Flag {
+	algorithm := Settings().SigningHash
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+
+	return cli.StringFlag{
+		Name:   "algorithm",
+		EnvVar: "LITHIUM_SIGNING_HASH",
+		Usage:  usage,
+		Value:  algorithm,
+	}
+}
+
+// CertManager constructs a CertManager for the given product, using the
+// folder selected by CertPathFlag. Every certificate and license issued
+// through it is recorded within the product's issuance ledger.
+func CertManager(c *cli.Context, product *license.Product) (*license.CertManager, error) {
+	cm := license.NewCertManager(product)
+	if path := CertPath(c); path != "" {
+		cm.Path = path
+	}
+
+	ledger, err := license.OpenFileLedger(filepath.Join(cm.Path, fmt.Sprintf("%s.ledger", product.ID)))
+	if err != nil {
+		return nil, err
+	}
+
+	cm.Ledger = ledger
+	return cm, nil
+}
+
+// LicenseIssuer prepares the certificate manager and signing key used to
+// issue licenses for the given application. The returned function must be
+// called once the signing key is no longer required.
+func LicenseIssuer(c *cli.Context, productID string) (*license.CertManager, crypto.Signer, func(), error) {
+	product, err := LoadProduct(c.GlobalString("licensePath"), productID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cm, err := CertManager(c, product)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	chain, err := cm.GetChain()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if chain == nil {
+		return nil, nil, nil, fmt.Errorf("no certificate exists for application '%s'", product.ID)
+	}
+
+	err = UnlockKey(c, cm)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	key, closeKey, err := LoadSigningKey(cm, chain)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return cm, key, closeKey, nil
+}
+
+// RootFlag returns the flag used to provide the root certificate against
+// which licenses should be validated.
+func RootFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:  "root",
+		Usage: "a `file` containing the trusted root certificate, defaults to the application's root certificate",
+	}
+}
+
+// TrustedRoot loads the root certificate against which licenses should be
+// validated, either from the file provided using --root or from the chain
+// of the application selected using --product, as provided by RootFlag and
+// KeyFlags respectively.
+func TrustedRoot(c *cli.Context) (*x509.Certificate, error) {
+	if file := c.String("root"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		chain, err := license.ParseChain(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return chain.Root(), nil
+	}
+
+	product := c.String("product")
+	if product == "" || product == license.DefaultKeyProduct {
+		return nil, errors.New("expected you to provide the ID of the application or a root certificate")
+	}
+
+	p, err := LoadProduct(c.GlobalString("licensePath"), product)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := CertManager(c, p)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := cm.GetChain()
+	if err != nil {
+		return nil, err
+	}
+
+	if chain == nil {
+		return nil, fmt.Errorf("no certificate exists for application '%s'", product)
+	}
+
+	return chain.Root(), nil
+}
@@ -0,0 +1,210 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// ConfigFile is the name of the workspace configuration file, which is
+// found by searching the working directory and each of its parents.
+const ConfigFile = ".litmus.json"
+
+// Config holds the settings used as the defaults of litmus' flags. It is
+// read from several layers of configuration files, with each layer taking
+// precedence over those before it, and is itself overridden by flags and
+// environment variables.
+type Config struct {
+	// LicensePath is the folder within which products, templates,
+	// certificates and signing keys are stored. Relative paths are
+	// resolved against the folder holding the configuration file.
+	LicensePath string `json:"licensePath,omitempty"`
+
+	// Product is the ID of the product used by commands which are not
+	// provided with one.
+	Product string `json:"product,omitempty"`
+
+	// KeyAlgorithm and KeySize describe the keys generated by litmus.
+	KeyAlgorithm string `json:"keyAlgorithm,omitempty"`
+	KeySize      int    `json:"keySize,omitempty"`
+
+	// SigningHash is the hash algorithm used to sign licenses.
+	SigningHash string `json:"signingHash,omitempty"`
+
+	// Output is the format in which commands write their results.
+	Output string `json:"output,omitempty"`
+}
+
+// ConfigPaths returns the configuration files read by LoadConfig, in the
+// order in which they are applied: the system configuration, the user's
+// configuration, the workspace configuration and finally any file named
+// by the LITHIUM_CONFIG environment variable.
+func ConfigPaths() []string {
+	paths := []string{}
+
+	if runtime.GOOS == "windows" {
+		paths = append(paths, filepath.Join(os.Getenv("ProgramData"), "Lithium", "litmus.json"))
+	} else {
+		paths = append(paths, "/etc/lithium/litmus.json")
+	}
+
+	user := filepath.Join(os.ExpandEnv("$HOME"), ConfigFile)
+	paths = append(paths, user)
+
+	if cwd, err := filepath.Abs("./"); err == nil {
+		for dir := cwd; ; dir = filepath.Dir(dir) {
+			file := filepath.Join(dir, ConfigFile)
+			if _, err := os.Stat(file); err == nil {
+				if file != user {
+					paths = append(paths, file)
+				}
+
+				break
+			}
+
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	if file := os.Getenv("LITHIUM_CONFIG"); file != "" {
+		paths = append(paths, file)
+	}
+
+	return paths
+}
+
+var loadedConfig struct {
+	config *Config
+	files  []string
+	err    error
+	once   sync.Once
+}
+
+// LoadConfig reads and merges each of the files returned by ConfigPaths,
+// skipping those which do not exist, and returns the resulting settings
+// along with the files from which they were read. The configuration is
+// only read once.
+func LoadConfig() (*Config, []string, error) {
+	loadedConfig.once.Do(func() {
+		config := &Config{}
+		files := []string{}
+
+		for _, file := range ConfigPaths() {
+			layer, err := readConfig(file)
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				loadedConfig.err = fmt.Errorf("could not read configuration file '%s': %s", file, err)
+				return
+			}
+
+			config.merge(layer)
+			files = append(files, file)
+		}
+
+		loadedConfig.config = config
+		loadedConfig.files = files
+	})
+
+	return loadedConfig.config, loadedConfig.files, loadedConfig.err
+}
+
+// Settings returns the configuration read by LoadConfig, or an empty
+// configuration if it could not be read.
+func Settings() *Config {
+	config, _, err := LoadConfig()
+	if err != nil {
+		return &Config{}
+	}
+
+	return config
+}
+
+func readConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.LicensePath != "" && !filepath.IsAbs(config.LicensePath) {
+		dir, err := filepath.Abs(filepath.Dir(file))
+		if err != nil {
+			return nil, err
+		}
+
+		config.LicensePath = filepath.Join(dir, config.LicensePath)
+	}
+
+	return &config, nil
+}
+
+func (c *Config) merge(layer *Config) {
+	if layer.LicensePath != "" {
+		c.LicensePath = layer.LicensePath
+	}
+
+	if layer.Product != "" {
+		c.Product = layer.Product
+	}
+
+	if layer.KeyAlgorithm != "" {
+		c.KeyAlgorithm = layer.KeyAlgorithm
+	}
+
+	if layer.KeySize != 0 {
+		c.KeySize = layer.KeySize
+	}
+
+	if layer.SigningHash != "" {
+		c.SigningHash = layer.SigningHash
+	}
+
+	if layer.Output != "" {
+		c.Output = layer.Output
+	}
+}
+
+// ConfigOutput is the result of commands which describe the configuration
+// in use, along with the files from which it was read.
+type ConfigOutput struct {
+	*Config
+	Files []string `json:"files"`
+}
+
+// WriteTable writes the configured settings and the files they were read
+// from.
+func (c *ConfigOutput) WriteTable(w io.Writer) {
+	writeOptional(w, "License Path", c.LicensePath)
+	writeOptional(w, "Product", c.Product)
+	writeOptional(w, "Key Algorithm", c.KeyAlgorithm)
+	if c.KeySize != 0 {
+		writeOptional(w, "Key Size", fmt.Sprintf("%d", c.KeySize))
+	}
+	writeOptional(w, "Signing Hash", c.SigningHash)
+	writeOptional(w, "Output", c.Output)
+
+	if len(c.Files) == 0 {
+		fmt.Fprintln(w, "Files:         none")
+		return
+	}
+
+	fmt.Fprintln(w, "Files:")
+	for _, file := range c.Files {
+		fmt.Fprintf(w, "  %s\n", file)
+	}
+}
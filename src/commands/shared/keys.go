@@ -0,0 +1,211 @@
+// Package shared provides helpers which are used by several of the
+// litmus command groups.
+package shared
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/license/hsm"
+	"github.com/codegangsta/cli"
+)
+
+// LoadSigningKey retrieves the private key referenced by a certificate
+// chain. Keys held on a PKCS#11 token are accessed using the token
+// configured through the LITHIUM_PKCS11_* environment variables, while
+// key files and key shares must first be unlocked using UnlockKey. The
+// returned function must be called once the key is no longer required.
+func LoadSigningKey(cm *license.CertManager, chain *license.Chain) (crypto.Signer, func(), error) {
+	if chain.KeyRef == "" {
+		return nil, nil, errors.New("the certificate chain does not reference a private key")
+	}
+
+	if label, ok := hsm.ParseKeyRef(chain.KeyRef); ok {
+		token, err := hsm.Open(&hsm.Config{
+			Module:     os.Getenv("LITHIUM_PKCS11_MODULE"),
+			TokenLabel: os.Getenv("LITHIUM_PKCS11_TOKEN"),
+			PIN:        os.Getenv("LITHIUM_PKCS11_PIN"),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key, err := token.Signer(label)
+		if err != nil {
+			token.Close()
+			return nil, nil, err
+		}
+
+		return key, func() { token.Close() }, nil
+	}
+
+	if key := cm.UnlockedKey(); key != nil {
+		return key, func() {}, nil
+	}
+
+	if chain.KeyRef == license.KeySharesRef {
+		return nil, nil, errors.New("the private key has been split into shares, provide them using --share")
+	}
+
+	return nil, nil, errors.New("the private key has not been unlocked")
+}
+
+// KeyFlags returns the flags used to select the machine key which should
+// be used by a command.
+func KeyFlags() []cli.Flag {
+	km := license.NewKeyManager(nil)
+
+	product := Settings().Product
+	if product == "" {
+		product = license.DefaultKeyProduct
+	}
+
+	algorithm := Settings().KeyAlgorithm
+	if algorithm == "" {
+		algorithm = license.DefaultKeyAlgorithm
+	}
+
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "machineCode",
+			EnvVar: "LITHIUM_MACHINE_CODE",
+			Usage:  "the machine code used to protect this machine's private key",
+		},
+		cli.StringFlag{
+			Name:   "machineCodeSource",
+			EnvVar: "LITHIUM_MACHINE_CODE_SOURCE",
+			Usage:  fmt.Sprintf("the `source` of the machine code if --machineCode is not provided, one of %s, defaults to machine-id", strings.Join(license.MachineCodeSources(), ", ")),
+		},
+		cli.StringFlag{
+			Name:   "keyPath",
+			EnvVar: "LITHIUM_KEY_PATH",
+			Usage:  "the `path` under which this machine's keys are stored",
+			Value:  km.Path,
+		},
+		cli.StringFlag{
+			Name:   "product",
+			EnvVar: "LITHIUM_PRODUCT",
+			Usage:  "the ID of the product whose keys should be used",
+			Value:  product,
+		},
+		cli.StringFlag{
+			Name:   "profile",
+			EnvVar: "LITHIUM_KEY_PROFILE",
+			Usage:  "the name of the key profile which should be used",
+			Value:  license.DefaultKeyProfile,
+		},
+		cli.StringFlag{
+			Name:   "keyAlgorithm",
+			EnvVar: "LITHIUM_KEY_ALGORITHM",
+			Usage:  "the `algorithm` used to generate this machine's keypair if it does not exist",
+			Value:  algorithm,
+		},
+		KeySizeFlag(license.DefaultKeySize),
+	}
+}
+
+// KeySizeFlag returns the flag used to select the size of the keys which
+// a command generates, with the given default if no size is configured.
+func KeySizeFlag(defaultSize int) cli.Flag {
+	size := Settings().KeySize
+	if size == 0 {
+		size = defaultSize
+	}
+
+	return cli.IntFlag{
+		Name:   "keySize",
+		EnvVar: "LITHIUM_KEY_SIZE",
+		Usage:  "the length, in bits, of the keys which are generated",
+		Value:  size,
+	}
+}
+
+// KeyManager constructs a KeyManager for the machine key selected by the
+// flags returned from KeyFlags. The machine code is read from the source
+// selected by --machineCodeSource unless it is provided directly, and
+// otherwise from this system's machine ID.
+func KeyManager(c *cli.Context) (*license.KeyManager, error) {
+	machineCode := []byte(c.String("machineCode"))
+	if len(machineCode) == 0 {
+		spec := c.String("machineCodeSource")
+		if spec == "" {
+			spec = "machine-id"
+		}
+
+		source, err := license.ParseMachineCodeSource(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		machineCode, err = source.MachineCode()
+		if err != nil {
+			return nil, fmt.Errorf("could not determine this machine's code, provide one using --machineCode or --machineCodeSource: %s", err)
+		}
+
+		if len(machineCode) == 0 {
+			return nil, fmt.Errorf("the machine code provided by '%s' is empty, provide one using --machineCode or --machineCodeSource", spec)
+		}
+	}
+
+	km := license.NewKeyManager(machineCode)
+	km.Path = c.String("keyPath")
+	km.Product = c.String("product")
+	km.Profile = c.String("profile")
+	km.LegacyProduct = Settings().Product
+	km.Algorithm = c.String("keyAlgorithm")
+	km.KeySize = c.Int("keySize")
+
+	return km, nil
+}
+
+// ShareFlag returns the flag used to provide the key shares required to
+// unlock a private key which has been split into shares.
+func ShareFlag() cli.Flag {
+	return cli.StringSliceFlag{
+		Name:   "share",
+		EnvVar: "LITHIUM_KEY_SHARES",
+		Usage:  "a `file` containing a share of the signing key, may be repeated",
+	}
+}
+
+// UnlockKey unlocks the certificate manager's signing key, either by
+// recombining the key shares provided through ShareFlag or by decrypting
+// its key file with the passphrase selected by PassphraseFlags. Keys held
+// on a PKCS#11 token do not need to be unlocked.
+func UnlockKey(c *cli.Context, cm *license.CertManager) error {
+	files := c.StringSlice("share")
+	if len(files) > 0 {
+		shares := make([][]byte, len(files))
+		for i, file := range files {
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+
+			shares[i] = data
+		}
+
+		return cm.Unlock(shares)
+	}
+
+	chain, err := cm.GetChain()
+	if err != nil || chain == nil {
+		return err
+	}
+
+	if _, ok := hsm.ParseKeyRef(chain.KeyRef); ok || chain.KeyRef == "" || chain.KeyRef == license.KeySharesRef {
+		return nil
+	}
+
+	passphrase, err := Passphrase(c, "Enter passphrase for the signing key: ", false)
+	if err != nil {
+		return err
+	}
+
+	return cm.UnlockKey(passphrase)
+}
@@ -0,0 +1,178 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// MetadataFlags returns the flags used to describe the metadata of a
+// license, or of the certificate issued for one.
+func MetadataFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "id",
+			Usage: "the license ID, a random ID will be used if not provided",
+		},
+		cli.StringFlag{
+			Name:  "activates",
+			Usage: "the `time` (RFC3339) from which the license is valid, defaults to now",
+		},
+		cli.StringFlag{
+			Name:  "expires",
+			Usage: "the `time` (RFC3339) at which the license expires, overrides --duration",
+		},
+		cli.DurationFlag{
+			Name:  "duration",
+			Usage: "the `duration` for which the license is valid",
+			Value: 365 * 24 * time.Hour,
+		},
+		cli.StringFlag{
+			Name:  "pack",
+			Usage: "a JSON `file` describing the license templates the license holder may issue",
+		},
+	}
+}
+
+// LicenseMetadata builds license metadata from the flags returned by
+// MetadataFlags.
+func LicenseMetadata(c *cli.Context) (*license.Metadata, error) {
+	meta := license.Metadata{
+		ID: c.String("id"),
+	}
+
+	if meta.ID == "" {
+		id, err := license.NewID()
+		if err != nil {
+			return nil, err
+		}
+
+		meta.ID = id
+	}
+
+	activatesOn, expiresOn, err := ValidityPeriod(c.String("activates"), c.String("expires"), c.Duration("duration"))
+	if err != nil {
+		return nil, err
+	}
+
+	meta.ActivatesOn = activatesOn
+	meta.ExpiresOn = expiresOn
+
+	if packFile := c.String("pack"); packFile != "" {
+		meta.Pack, err = ReadPack(packFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &meta, nil
+}
+
+// ReadPack reads a JSON file describing the license templates which the
+// holder of a license may issue.
+func ReadPack(file string) (map[string]*license.Template, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	pack := map[string]*license.Template{}
+	err = json.Unmarshal(data, &pack)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pack file: %s", err)
+	}
+
+	return pack, nil
+}
+
+// ValidityPeriod determines the period for which a license is valid from
+// its activation and expiry times, each RFC3339 formatted, and its
+// duration. The license activates now if no activation time is provided
+// and an expiry time, if provided, overrides the duration.
+func ValidityPeriod(activates, expires string, duration time.Duration) (time.Time, time.Time, error) {
+	activatesOn := time.Now()
+	if activates != "" {
+		t, err := time.Parse(time.RFC3339, activates)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid activation time: %s", err)
+		}
+
+		activatesOn = t
+	}
+
+	expiresOn := activatesOn.Add(duration)
+	if expires != "" {
+		t, err := time.Parse(time.RFC3339, expires)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid expiry time: %s", err)
+		}
+
+		expiresOn = t
+	}
+
+	if !expiresOn.After(activatesOn) {
+		return time.Time{}, time.Time{}, fmt.Errorf("expected the license to expire after it activates")
+	}
+
+	return activatesOn, expiresOn, nil
+}
+
+// PayloadFlags returns the flags used to provide the payload of a license.
+func PayloadFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:  "payloadFile",
+			Usage: "a JSON `file` containing the license payload",
+		},
+		cli.StringSliceFlag{
+			Name:  "payload",
+			Usage: "a `key=value` entry to include in the license payload, values are parsed as JSON where possible",
+		},
+	}
+}
+
+// LicensePayload builds a license payload from the flags returned by
+// PayloadFlags. Entries provided using --payload override those read
+// from the payload file.
+func LicensePayload(c *cli.Context) (map[string]interface{}, error) {
+	payload := map[string]interface{}{}
+
+	if payloadFile := c.String("payloadFile"); payloadFile != "" {
+		data, err := ioutil.ReadFile(payloadFile)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(data, &payload)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload file: %s", err)
+		}
+	}
+
+	for _, entry := range c.StringSlice("payload") {
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid payload entry '%s', expected key=value", entry)
+		}
+
+		payload[parts[0]] = PayloadValue(parts[1])
+	}
+
+	return payload, nil
+}
+
+// PayloadValue parses a payload value provided as text, treating it as
+// JSON where possible and as a string otherwise.
+func PayloadValue(text string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return text
+	}
+
+	return value
+}
@@ -0,0 +1,97 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// The output formats supported by OutputFlag.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
+// OutputFlag returns the global flag used to select the format in which
+// commands write their results.
+func OutputFlag() cli.Flag {
+	format := Settings().Output
+	if format == "" {
+		format = OutputTable
+	}
+
+	return cli.StringFlag{
+		Name:   "output",
+		EnvVar: "LITHIUM_OUTPUT",
+		Usage:  "the `format` of command output, one of table, json or yaml",
+		Value:  format,
+	}
+}
+
+// Table is implemented by command results which can present themselves
+// as human readable text.
+type Table interface {
+	WriteTable(w io.Writer)
+}
+
+// OutputFormat retrieves the output format selected by OutputFlag.
+func OutputFormat(c *cli.Context) string {
+	format := strings.ToLower(c.GlobalString("output"))
+	if format == "" {
+		return OutputTable
+	}
+
+	return format
+}
+
+// Print writes a command's result to stdout in the format selected by
+// OutputFlag. Results are written using their WriteTable method in table
+// format, and otherwise using their JSON encoding, which YAML output
+// mirrors, so that scripts may rely on the same field names in either.
+func Print(c *cli.Context, result Table) error {
+	switch OutputFormat(c) {
+	case OutputTable:
+		result.WriteTable(os.Stdout)
+		return nil
+
+	case OutputJSON:
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		fmt.Println(string(data))
+		return nil
+
+	case OutputYAML:
+		data, err := json.Marshal(result)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		// JSON is a subset of YAML, so the JSON encoding is re-encoded to
+		// retain its field names.
+		var value interface{}
+		err = yaml.Unmarshal(data, &value)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		data, err = yaml.Marshal(value)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+
+		fmt.Print(string(data))
+		return nil
+
+	default:
+		return cli.NewExitError(fmt.Sprintf("unsupported output format '%s', expected table, json or yaml", OutputFormat(c)), 1)
+	}
+}
@@ -0,0 +1,173 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/codegangsta/cli"
+	"golang.org/x/term"
+)
+
+// PassphraseFlags returns the flags used to provide the passphrase which
+// protects product and intermediate signing keys. If neither is provided
+// the passphrase is requested through an interactive prompt.
+func PassphraseFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "passphrase",
+			EnvVar: "LITHIUM_KEY_PASSPHRASE",
+			Usage:  "the passphrase used to protect the signing key",
+		},
+		cli.IntFlag{
+			Name:  "passphraseFd",
+			Usage: "a file descriptor from which the passphrase used to protect the signing key should be read",
+			Value: -1,
+		},
+	}
+}
+
+// NewPassphraseFlags returns the flags used to provide the passphrase which
+// protects a newly created signing key, for commands which must also unlock
+// an existing key using the flags returned from PassphraseFlags. If neither
+// is provided the passphrase is requested through an interactive prompt.
+func NewPassphraseFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "newPassphrase",
+			EnvVar: "LITHIUM_NEW_KEY_PASSPHRASE",
+			Usage:  "the passphrase used to protect the new signing key",
+		},
+		cli.IntFlag{
+			Name:  "newPassphraseFd",
+			Usage: "a file descriptor from which the passphrase used to protect the new signing key should be read",
+			Value: -1,
+		},
+	}
+}
+
+// BundlePassphraseFlags returns the flags used to provide the passphrase
+// which protects a machine's exported key bundle. If neither is provided
+// the passphrase is requested through an interactive prompt.
+func BundlePassphraseFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "passphrase",
+			EnvVar: "LITHIUM_BUNDLE_PASSPHRASE",
+			Usage:  "the passphrase used to protect the bundle",
+		},
+		cli.IntFlag{
+			Name:  "passphraseFd",
+			Usage: "a file descriptor from which the passphrase used to protect the bundle should be read",
+			Value: -1,
+		},
+	}
+}
+
+// fdPassphrases holds the passphrases read from file descriptors, which
+// can only be read once, so that they may be retrieved again by later
+// calls.
+var (
+	fdPassphrases     = map[int][]byte{}
+	fdPassphrasesLock sync.Mutex
+)
+
+// Passphrase retrieves the signing key passphrase selected by the flags
+// returned from PassphraseFlags, prompting for it on the terminal if it
+// was not otherwise provided. When confirm is set, a prompted passphrase
+// must be entered twice.
+func Passphrase(c *cli.Context, prompt string, confirm bool) ([]byte, error) {
+	return passphrase(c, "passphrase", "passphraseFd", "LITHIUM_KEY_PASSPHRASE", prompt, confirm)
+}
+
+// NewPassphrase retrieves the passphrase for a newly created signing key,
+// selected by the flags returned from NewPassphraseFlags, prompting for it,
+// and its confirmation, on the terminal if it was not otherwise provided.
+// The passphrase of the key being unlocked is never reused for the new key.
+func NewPassphrase(c *cli.Context, prompt string) ([]byte, error) {
+	return passphrase(c, "newPassphrase", "newPassphraseFd", "LITHIUM_NEW_KEY_PASSPHRASE", prompt, true)
+}
+
+// BundlePassphrase retrieves the bundle passphrase selected by the flags
+// returned from BundlePassphraseFlags, prompting for it on the terminal if
+// it was not otherwise provided. When confirm is set, a prompted passphrase
+// must be entered twice.
+func BundlePassphrase(c *cli.Context, prompt string, confirm bool) ([]byte, error) {
+	return passphrase(c, "passphrase", "passphraseFd", "LITHIUM_BUNDLE_PASSPHRASE", prompt, confirm)
+}
+
+func passphrase(c *cli.Context, flag, fdFlag, envVar, prompt string, confirm bool) ([]byte, error) {
+	if passphrase := c.String(flag); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+
+	if fd := c.Int(fdFlag); fd >= 0 {
+		return fdPassphrase(fd)
+	}
+
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, fmt.Errorf("expected you to provide a passphrase using --%s, --%s or %s", flag, fdFlag, envVar)
+	}
+
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(passphrase) == 0 {
+		return nil, errors.New("expected a passphrase to be provided")
+	}
+
+	if confirm {
+		again, err := readPassphrase("Confirm passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(passphrase, again) {
+			return nil, errors.New("the passphrases did not match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+// fdPassphrase reads a passphrase from the first line of the given file
+// descriptor, or returns the passphrase previously read from it.
+func fdPassphrase(fd int) ([]byte, error) {
+	fdPassphrasesLock.Lock()
+	defer fdPassphrasesLock.Unlock()
+
+	if passphrase, ok := fdPassphrases[fd]; ok {
+		return passphrase, nil
+	}
+
+	f := os.NewFile(uintptr(fd), "passphrase")
+	if f == nil {
+		return nil, fmt.Errorf("invalid passphrase file descriptor %d", fd)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		if err != nil {
+			return nil, fmt.Errorf("could not read passphrase: %s", err)
+		}
+
+		return nil, errors.New("expected a passphrase to be provided")
+	}
+
+	fdPassphrases[fd] = line
+	return line, nil
+}
+
+func readPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	defer fmt.Fprintln(os.Stderr)
+
+	return term.ReadPassword(int(os.Stdin.Fd()))
+}
@@ -0,0 +1,100 @@
+package shared
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/codegangsta/cli"
+)
+
+func TestPassphraseFd(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.WriteString("root secret\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	app := cli.NewApp()
+	app.Flags = append(PassphraseFlags(), NewPassphraseFlags()...)
+	app.Action = func(c *cli.Context) error {
+		for i := 0; i < 2; i++ {
+			passphrase, err := Passphrase(c, "", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(passphrase) != "root secret" {
+				t.Errorf("expected the passphrase to be read from the file descriptor, got '%s'", passphrase)
+			}
+		}
+
+		if c.String("passphrase") != "" {
+			t.Error("expected the passphrase read from the file descriptor not to be stored in the flags")
+		}
+
+		if passphrase, err := NewPassphrase(c, ""); err == nil {
+			t.Errorf("expected the passphrase for a new key not to reuse the existing key's, got '%s'", passphrase)
+		}
+
+		c.Set("newPassphrase", "intermediate secret")
+		passphrase, err := NewPassphrase(c, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(passphrase) != "intermediate secret" {
+			t.Errorf("expected the new key's passphrase to be provided separately, got '%s'", passphrase)
+		}
+
+		return nil
+	}
+
+	err = app.Run([]string{"litmus", "--passphraseFd", strconv.Itoa(int(r.Fd()))})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundlePassphraseFd(t *testing.T) {
+	// File descriptors closed by earlier tests may be reused by the pipe.
+	fdPassphrasesLock.Lock()
+	fdPassphrases = map[int][]byte{}
+	fdPassphrasesLock.Unlock()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.WriteString("bundle secret\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	app := cli.NewApp()
+	app.Flags = BundlePassphraseFlags()
+	app.Action = func(c *cli.Context) error {
+		passphrase, err := BundlePassphrase(c, "", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(passphrase) != "bundle secret" {
+			t.Errorf("expected the bundle passphrase to be read from the file descriptor, got '%s'", passphrase)
+		}
+
+		return nil
+	}
+
+	err = app.Run([]string{"litmus", "--passphraseFd", strconv.Itoa(int(r.Fd()))})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
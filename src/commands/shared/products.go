@@ -0,0 +1,114 @@
+package shared
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// ProductFlag returns the flag used to select the product a command acts
+// upon, described by the given usage.
+func ProductFlag(usage string) cli.Flag {
+	return cli.StringFlag{
+		Name:   "product",
+		EnvVar: "LITHIUM_PRODUCT",
+		Usage:  usage,
+		Value:  Settings().Product,
+	}
+}
+
+// LoadProduct retrieves the definition of the product with the given ID
+// from the license path.
+func LoadProduct(licensePath, id string) (*license.Product, error) {
+	product, err := ParseProductFile(ProductFile(licensePath, id))
+	if err != nil {
+		return nil, fmt.Errorf("could not read application '%s': %s", id, err)
+	}
+
+	return product, nil
+}
+
+// ParseProductFile reads a product definition from the given file.
+func ParseProductFile(file string) (*license.Product, error) {
+	var product license.Product
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &product)
+	if err != nil {
+		return nil, err
+	}
+
+	err = product.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// ListProducts retrieves the definitions of every product in the license
+// path. Files which do not contain a valid product definition, or whose
+// name does not match the product's ID, are skipped and their names are
+// returned alongside the products.
+func ListProducts(licensePath string) ([]*license.Product, []string, error) {
+	files, err := filepath.Glob(filepath.Join(licensePath, "*.json"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	products := []*license.Product{}
+	skipped := []string{}
+	for _, file := range files {
+		product, err := ParseProductFile(file)
+		if err != nil || filepath.Base(file) != fmt.Sprintf("%s.json", product.ID) {
+			skipped = append(skipped, file)
+			continue
+		}
+
+		products = append(products, product)
+	}
+
+	return products, skipped, nil
+}
+
+// SaveProduct validates a product's definition and writes it to the
+// license path.
+func SaveProduct(licensePath string, product *license.Product) error {
+	err := product.Validate()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(licensePath, os.ModePerm|os.ModeDir)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(licensePath, fmt.Sprintf("%s.json", product.ID)), data, 0644)
+}
+
+// ProductFile returns the path of the file in which the product with the
+// given ID is defined.
+func ProductFile(licensePath, id string) string {
+	return filepath.Join(licensePath, fmt.Sprintf("%s.json", id))
+}
@@ -0,0 +1,270 @@
+package shared
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// The statuses reported for licenses by LicenseOutput.
+const (
+	LicenseValid        = "valid"
+	LicenseExpired      = "expired"
+	LicenseNotActivated = "not activated"
+	LicenseInvalid      = "invalid"
+	LicenseUnknown      = "unknown"
+)
+
+// ProductList is the result of commands which list products.
+type ProductList []*license.Product
+
+// WriteTable writes the products as a table of their IDs and names.
+func (l ProductList) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "%-20s %-25s %-25s\n", "ID", "Name", "Organization")
+
+	for _, p := range l {
+		fmt.Fprintf(w, "%-20s %-25s %-25s\n", p.ID, p.Name, p.Organization)
+	}
+}
+
+// ProductOutput is the result of commands which describe a product and
+// its certificate chain.
+type ProductOutput struct {
+	*license.Product
+	Chain *ChainOutput `json:"chain,omitempty"`
+}
+
+// WriteTable writes the product's definition and a summary of its chain.
+func (p *ProductOutput) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "ID:            %s\n", p.ID)
+	fmt.Fprintf(w, "Name:          %s\n", p.Name)
+	fmt.Fprintf(w, "Organization:  %s\n", p.Organization)
+	writeOptional(w, "Unit", p.OrganizationalUnit)
+	writeOptional(w, "Locality", p.Locality)
+	writeOptional(w, "Province", p.Province)
+	writeOptional(w, "Country", p.Country)
+	fmt.Fprintf(w, "Root Validity: %d days\n", int(p.RootValidity()/(24*time.Hour)))
+	writeOptional(w, "Key Usage", strings.Join(p.KeyUsage, ", "))
+	writeOptional(w, "DNS Names", strings.Join(p.DNSNames, ", "))
+	writeOptional(w, "URIs", strings.Join(p.URIs, ", "))
+
+	if p.Chain == nil || len(p.Chain.Certificates) == 0 {
+		fmt.Fprintln(w, "Certificate:   not created")
+		return
+	}
+
+	leaf := p.Chain.Certificates[len(p.Chain.Certificates)-1]
+	fmt.Fprintf(w, "Certificate:   %s\n", p.Chain.File)
+	fmt.Fprintf(w, "Chain Length:  %d\n", len(p.Chain.Certificates))
+	fmt.Fprintf(w, "Valid:         %s to %s\n", leaf.NotBefore.Format(time.RFC3339), leaf.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "Fingerprint:   %s\n", leaf.ShortFingerprint)
+	writeOptional(w, "Key", p.Chain.Key)
+}
+
+// ChainOutput describes a certificate chain, ordered from its root.
+type ChainOutput struct {
+	File         string               `json:"file,omitempty"`
+	Key          string               `json:"key,omitempty"`
+	Certificates []*CertificateOutput `json:"certificates"`
+}
+
+// NewChainOutput describes the given certificate chain.
+func NewChainOutput(chain *license.Chain, file string) *ChainOutput {
+	return &ChainOutput{
+		File:         file,
+		Key:          chain.KeyRef,
+		Certificates: NewCertificateOutputs(chain.Certificates),
+	}
+}
+
+// CertificateOutput describes a certificate and its license binding.
+type CertificateOutput struct {
+	Subject          string         `json:"subject"`
+	SubjectSerial    string         `json:"subjectSerial,omitempty"`
+	Issuer           string         `json:"issuer"`
+	Serial           string         `json:"serial"`
+	Algorithm        string         `json:"algorithm"`
+	NotBefore        time.Time      `json:"notBefore"`
+	NotAfter         time.Time      `json:"notAfter"`
+	CA               bool           `json:"ca"`
+	Fingerprint      string         `json:"fingerprint"`
+	ShortFingerprint string         `json:"shortFingerprint"`
+	Binding          *BindingOutput `json:"binding,omitempty"`
+	BindingError     string         `json:"bindingError,omitempty"`
+}
+
+// BindingOutput describes the product and license to which a certificate
+// is bound.
+type BindingOutput struct {
+	Product  string `json:"product"`
+	License  string `json:"license,omitempty"`
+	Template string `json:"template,omitempty"`
+}
+
+// NewCertificateOutput describes the given certificate.
+func NewCertificateOutput(cert *x509.Certificate) *CertificateOutput {
+	out := &CertificateOutput{
+		Subject:       cert.Subject.CommonName,
+		SubjectSerial: cert.Subject.SerialNumber,
+		Issuer:        cert.Issuer.CommonName,
+		Serial:        cert.SerialNumber.Text(16),
+		Algorithm:     cert.SignatureAlgorithm.String(),
+		NotBefore:     cert.NotBefore,
+		NotAfter:      cert.NotAfter,
+		CA:            cert.IsCA,
+	}
+
+	out.Fingerprint, _ = license.KeyFingerprint(cert.PublicKey)
+	out.ShortFingerprint, _ = license.ShortFingerprint(cert.PublicKey)
+
+	binding, err := license.ParseLicenseBinding(cert)
+	if err != nil {
+		out.BindingError = err.Error()
+	} else if binding != nil {
+		out.Binding = &BindingOutput{
+			Product:  binding.ProductID,
+			License:  binding.LicenseID,
+			Template: binding.Template,
+		}
+	}
+
+	return out
+}
+
+// NewCertificateOutputs describes each of the given certificates.
+func NewCertificateOutputs(certs []*x509.Certificate) []*CertificateOutput {
+	out := make([]*CertificateOutput, len(certs))
+	for i, cert := range certs {
+		out[i] = NewCertificateOutput(cert)
+	}
+
+	return out
+}
+
+// WriteTable writes the certificate's details, indented for inclusion
+// within a larger result.
+func (c *CertificateOutput) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "  Subject:     %s\n", c.Subject)
+	if c.SubjectSerial != "" {
+		fmt.Fprintf(w, "  Subject SN:  %s\n", c.SubjectSerial)
+	}
+	fmt.Fprintf(w, "  Issuer:      %s\n", c.Issuer)
+	fmt.Fprintf(w, "  Serial:      %s\n", c.Serial)
+	fmt.Fprintf(w, "  Algorithm:   %s\n", c.Algorithm)
+	fmt.Fprintf(w, "  Valid:       %s to %s\n", c.NotBefore.Format(time.RFC3339), c.NotAfter.Format(time.RFC3339))
+	fmt.Fprintf(w, "  CA:          %t\n", c.CA)
+	fmt.Fprintf(w, "  Key:         %s\n", c.Fingerprint)
+
+	if c.BindingError != "" {
+		fmt.Fprintf(w, "  Binding:     %s\n", c.BindingError)
+	} else if c.Binding != nil {
+		fmt.Fprintf(w, "  Product:     %s\n", c.Binding.Product)
+		if c.Binding.License != "" {
+			fmt.Fprintf(w, "  License:     %s\n", c.Binding.License)
+		}
+		if c.Binding.Template != "" {
+			fmt.Fprintf(w, "  Template:    %s\n", c.Binding.Template)
+		}
+	}
+}
+
+// LicenseOutput describes a license and its current status, which is one
+// of LicenseValid, LicenseExpired, LicenseNotActivated or LicenseInvalid,
+// or LicenseUnknown if it could not be read.
+type LicenseOutput struct {
+	ID              string                       `json:"id"`
+	Product         string                       `json:"product,omitempty"`
+	Template        string                       `json:"template,omitempty"`
+	LicenseTemplate string                       `json:"licenseTemplate,omitempty"`
+	ActivatesOn     time.Time                    `json:"activates"`
+	ExpiresOn       time.Time                    `json:"expires"`
+	Status          string                       `json:"status"`
+	Error           string                       `json:"error,omitempty"`
+	File            string                       `json:"file,omitempty"`
+	Payload         map[string]interface{}       `json:"payload,omitempty"`
+	Pack            map[string]*license.Template `json:"pack,omitempty"`
+}
+
+// NewLicenseOutput describes the given license data.
+func NewLicenseOutput(data *license.Data) *LicenseOutput {
+	out := &LicenseOutput{
+		Payload: data.Payload,
+		Status:  LicenseValid,
+	}
+
+	if data.Meta == nil {
+		out.Status = LicenseInvalid
+		out.Error = "license metadata not defined"
+		return out
+	}
+
+	out.ID = data.Meta.ID
+	out.Product = data.Meta.Product
+	out.Template = data.Meta.Template
+	out.LicenseTemplate = data.Meta.LicenseTemplate
+	out.ActivatesOn = data.Meta.ActivatesOn
+	out.ExpiresOn = data.Meta.ExpiresOn
+	out.Pack = data.Meta.Pack
+
+	switch _, err := data.IsValid(); err {
+	case nil:
+	case license.ErrExpired:
+		out.Status = LicenseExpired
+	case license.ErrNotActivated:
+		out.Status = LicenseNotActivated
+	default:
+		out.Status = LicenseInvalid
+		out.Error = err.Error()
+	}
+
+	return out
+}
+
+// WriteTable writes a single line summary of the license.
+func (l *LicenseOutput) WriteTable(w io.Writer) {
+	if l.ActivatesOn.IsZero() {
+		fmt.Fprintf(w, "%s  %s\n", l.ID, l.describeStatus())
+		return
+	}
+
+	fmt.Fprintf(w, "%s  %s to %s  %s\n", l.ID, l.ActivatesOn.Format(time.RFC3339), l.ExpiresOn.Format(time.RFC3339), l.describeStatus())
+}
+
+func (l *LicenseOutput) describeStatus() string {
+	if l.Error != "" {
+		return fmt.Sprintf("%s: %s", l.Status, l.Error)
+	}
+
+	return l.Status
+}
+
+// ValidationOutput is the result of validating a license, where Code is
+// the exit code identifying the reason it was rejected, if any.
+type ValidationOutput struct {
+	Valid   bool           `json:"valid"`
+	Code    int            `json:"code"`
+	Reason  string         `json:"reason,omitempty"`
+	License *LicenseOutput `json:"license,omitempty"`
+}
+
+// WriteTable writes the outcome of the validation.
+func (v *ValidationOutput) WriteTable(w io.Writer) {
+	if v.Valid {
+		fmt.Fprintln(w, "The license is valid")
+		return
+	}
+
+	fmt.Fprintf(w, "The license is not valid: %s\n", v.Reason)
+}
+
+func writeOptional(w io.Writer, name, value string) {
+	if value == "" {
+		return
+	}
+
+	fmt.Fprintf(w, "%-15s%s\n", name+":", value)
+}
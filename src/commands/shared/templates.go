@@ -0,0 +1,62 @@
+package shared
+
+import (
+	"path/filepath"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/codegangsta/cli"
+)
+
+// TemplateFlag returns the flag used to select the named license template
+// from which a license should be issued.
+func TemplateFlag() cli.Flag {
+	return cli.StringFlag{
+		Name:  "template",
+		Usage: "the `name` of the license template providing the license's defaults",
+	}
+}
+
+// LicenseTemplates returns the store holding the named license templates
+// of the given product within the license path.
+func LicenseTemplates(licensePath, productID string) *license.FileTemplateStore {
+	return &license.FileTemplateStore{
+		Path: filepath.Join(licensePath, "templates", productID),
+	}
+}
+
+// LicenseData builds license data from the flags returned by MetadataFlags
+// and PayloadFlags, applying the product's named license template if one
+// is provided. Values provided using flags override the template's
+// defaults.
+func LicenseData(c *cli.Context, productID, template string) (*license.Data, error) {
+	meta, err := LicenseMetadata(c)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := LicensePayload(c)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &license.Data{
+		Meta:    meta,
+		Payload: payload,
+	}
+
+	if template == "" {
+		return data, nil
+	}
+
+	t, err := license.ResolveTemplate(LicenseTemplates(c.GlobalString("licensePath"), productID), template)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ValidityDays > 0 && !c.IsSet("duration") && c.String("expires") == "" {
+		meta.ExpiresOn = meta.ActivatesOn.Add(t.Validity())
+	}
+
+	t.Apply(data)
+	return data, nil
+}
@@ -0,0 +1,298 @@
+package license
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// LicenseRequestType is used to armour the offline license requests
+// produced by a KeyManager.
+const LicenseRequestType = "LITHIUM LICENSE REQUEST"
+
+// DefaultMaxClockSkew is the furthest that FulfilLicenseRequest will
+// backdate a license to cater for a machine's clock, unless the
+// CertManager's MaxClockSkew is configured.
+const DefaultMaxClockSkew = 15 * time.Minute
+
+// LicenseRequest describes a machine's request for a license, allowing
+// machines without network access to be licensed by carrying the request
+// to the vendor and the resulting license container back again.
+type LicenseRequest struct {
+	// PublicKey is the machine's public key, for which the license will be
+	// encrypted.
+	PublicKey *rsa.PublicKey
+
+	// Fingerprint is the fingerprint of the machine's public key.
+	Fingerprint string
+
+	// Product is the ID of the product for which a license is requested.
+	Product string
+
+	// Template is the name of the license template requested, if any.
+	Template string
+
+	// ClientTime is the time, according to the machine's clock, at which
+	// the request was created.
+	ClientTime time.Time
+
+	// LicenseID is the ID of the floating license whose seat the request
+	// renews or releases, if any.
+	LicenseID string
+
+	// Nonce is a random value identifying the request, allowing servers to
+	// refuse requests which are replayed.
+	Nonce string
+}
+
+type licenseRequestBody struct {
+	PublicKey   []byte    `json:"publicKey"`
+	Fingerprint string    `json:"fingerprint"`
+	Product     string    `json:"product"`
+	Template    string    `json:"template,omitempty"`
+	ClientTime  time.Time `json:"clientTime"`
+	LicenseID   string    `json:"licenseId,omitempty"`
+	Nonce       string    `json:"nonce,omitempty"`
+}
+
+type signedLicenseRequest struct {
+	Request   []byte `json:"request"`
+	Signature []byte `json:"signature"`
+	Algorithm string `json:"algorithm"`
+}
+
+// CreateLicenseRequest produces a PEM encoded license request for this
+// machine's public key and the KeyManager's product, optionally naming the
+// license template which should be used to fulfil it. The request is signed
+// using the machine's private key, proving possession of that key to the
+// vendor.
+func (m *KeyManager) CreateLicenseRequest(template string) ([]byte, error) {
+	return m.createLicenseRequest(template, "")
+}
+
+// CreateSeatRequest produces a license request, as with CreateLicenseRequest,
+// which renews or releases the seat of the floating license with the given
+// ID. The request names the license, so that it cannot be used to renew or
+// release any other seat.
+func (m *KeyManager) CreateSeatRequest(licenseID string) ([]byte, error) {
+	if licenseID == "" {
+		return nil, errors.New("expected the ID of the floating license to be provided")
+	}
+
+	return m.createLicenseRequest("", licenseID)
+}
+
+func (m *KeyManager) createLicenseRequest(template, licenseID string) ([]byte, error) {
+	if m.Product == "" || m.Product == DefaultKeyProduct {
+		return nil, errors.New("expected the key manager to be bound to a product")
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	pubData, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := KeyFingerprint(privKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(&licenseRequestBody{
+		PublicKey:   pubData,
+		Fingerprint: fingerprint,
+		Product:     m.Product,
+		Template:    template,
+		ClientTime:  time.Now().UTC(),
+		LicenseID:   licenseID,
+		Nonce:       nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashedData, err := computeHash(body, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := rsa.SignPSS(rand.Reader, privKey, crypto.SHA256, hashedData, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(&signedLicenseRequest{
+		Request:   body,
+		Signature: signature,
+		Algorithm: "sha256",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  LicenseRequestType,
+		Bytes: data,
+	}), nil
+}
+
+// ParseLicenseRequest parses a PEM encoded license request, verifying that
+// it was signed by the private key matching the public key it contains and
+// that its fingerprint matches that key.
+func ParseLicenseRequest(data []byte) (*LicenseRequest, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("license request was not a valid PEM block")
+	}
+
+	if block.Type != LicenseRequestType {
+		return nil, errors.New("license request was not of the correct type")
+	}
+
+	var signed signedLicenseRequest
+	err := json.Unmarshal(block.Bytes, &signed)
+	if err != nil {
+		return nil, err
+	}
+
+	var body licenseRequestBody
+	err = json.Unmarshal(signed.Request, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(body.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	pubKey, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("only RSA public keys supported")
+	}
+
+	hash, err := hashByName(signed.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	hashedData, err := computeHash(signed.Request, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rsa.VerifyPSS(pubKey, hash, hashedData, signed.Signature, nil)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	fingerprint, err := KeyFingerprint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if body.Fingerprint != fingerprint {
+		return nil, errors.New("license request fingerprint does not match its public key")
+	}
+
+	if body.Product == "" {
+		return nil, errors.New("license request does not specify a product")
+	}
+
+	return &LicenseRequest{
+		PublicKey:   pubKey,
+		Fingerprint: body.Fingerprint,
+		Product:     body.Product,
+		Template:    body.Template,
+		ClientTime:  body.ClientTime,
+		LicenseID:   body.LicenseID,
+		Nonce:       body.Nonce,
+	}, nil
+}
+
+// FulfilLicenseRequest issues a license for the machine which produced the
+// given request, as with IssueLicense. To cater for the machine's clock, a
+// license which would otherwise activate immediately is instead activated
+// from the time at which the request was created on the machine, though no
+// earlier than the CertManager's MaxClockSkew before its original time.
+func (m *CertManager) FulfilLicenseRequest(req *LicenseRequest, data *Data, privKey crypto.Signer, algorithm string) (*Container, error) {
+	if req == nil || req.PublicKey == nil {
+		return nil, errors.New("expected a license request to be provided")
+	}
+
+	if req.Product != m.Product.ID {
+		return nil, fmt.Errorf("license request is for product '%s', not '%s'", req.Product, m.Product.ID)
+	}
+
+	if data == nil || data.Meta == nil {
+		return nil, errors.New("expected license metadata to be provided")
+	}
+
+	data.Meta.Product = req.Product
+	if data.Meta.LicenseTemplate == "" {
+		data.Meta.LicenseTemplate = req.Template
+	}
+
+	if !req.ClientTime.IsZero() && req.ClientTime.Before(data.Meta.ActivatesOn) && !data.Meta.ActivatesOn.After(time.Now()) {
+		earliest := data.Meta.ActivatesOn.Add(-m.maxClockSkew())
+		if req.ClientTime.Before(earliest) {
+			data.Meta.ActivatesOn = earliest
+		} else {
+			data.Meta.ActivatesOn = req.ClientTime
+		}
+	}
+
+	return m.IssueLicense(data, req.PublicKey, privKey, algorithm)
+}
+
+func (m *CertManager) maxClockSkew() time.Duration {
+	if m.MaxClockSkew > 0 {
+		return m.MaxClockSkew
+	}
+
+	return DefaultMaxClockSkew
+}
+
+// InstallLicenseResponse validates a license container issued in response
+// to one of this machine's license requests against the product's root
+// certificate and installs it, as with InstallLicense.
+func (m *KeyManager) InstallLicenseResponse(response []byte, rootCert *x509.Certificate) (*Data, error) {
+	container, err := ParseContainer(response)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = container.IsValidFor(rootCert, m.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = container.LicenseFor(privKey, rootCert, m.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.InstallLicense(response)
+}
@@ -0,0 +1,408 @@
+package license
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LockedPayloadAlgorithm identifies payloads which are encrypted using a key
+// derived from an unlock key, rather than for a specific client's key, and
+// which must be activated using a response code before they can be read.
+const LockedPayloadAlgorithm = "aes256-locked"
+
+// UnlockKeyLabel is used to identify the unlock key of a locked payload
+// once it has been encrypted for the license's issuer.
+const UnlockKeyLabel = "Lithium License Unlock Key"
+
+// ActivationKeyLabel is used to identify the unlock key of a locked payload
+// once it has been encrypted for the machine which activated it.
+const ActivationKeyLabel = "Lithium License Activation Key"
+
+// activationSecretLabel is used to derive the X25519 key of a machine,
+// which its activation code carries, from the machine's private key.
+const activationSecretLabel = "Lithium Machine Activation Secret"
+
+// ErrNotUnlocked is returned when attempting to decrypt a locked license
+// which has not yet been activated.
+var ErrNotUnlocked = errors.New("license is locked and has not been activated")
+
+// The layout of activation and response codes, in bytes. Activation codes
+// carry the machine's X25519 public key and a digest of the product, while
+// response codes carry the license's unlock key, masked using the secret
+// the machine shares with the license's issuer, and a tag keyed by that
+// secret. Both end with a checksum to catch typing errors.
+const (
+	activationKeySize           = 32
+	activationProductDigestSize = 2
+	activationCodeSize          = activationKeySize + activationProductDigestSize + codeChecksumSize
+	unlockKeySize               = 10
+	responseTagSize             = 4
+	responseCodeSize            = unlockKeySize + responseTagSize + codeChecksumSize
+	codeChecksumSize            = 2
+	codeGroupSize               = 4
+)
+
+// codeEncoding is the Crockford base32 alphabet, which avoids characters
+// that are easily confused when read aloud or typed.
+var codeEncoding = base32.NewEncoding("0123456789ABCDEFGHJKMNPQRSTVWXYZ").WithPadding(base32.NoPadding)
+
+// ActivationCode is a human typeable code carrying a machine's X25519
+// public key, derived from its private key, and identifying the product it
+// wishes to activate. The issuer combines the key with the locked license's
+// response key to produce a response code which only that machine can use.
+type ActivationCode struct {
+	PublicKey     []byte
+	ProductDigest []byte
+}
+
+// NewActivationCode builds the activation code for the machine with the
+// given private key and the product.
+func NewActivationCode(privKey *rsa.PrivateKey, productID string) (*ActivationCode, error) {
+	key, err := activationKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	productDigest := sha256.Sum256([]byte(productID))
+
+	return &ActivationCode{
+		PublicKey:     key.PublicKey().Bytes(),
+		ProductDigest: productDigest[:activationProductDigestSize],
+	}, nil
+}
+
+// ParseActivationCode parses an activation code, verifying its checksum.
+// Letters may be provided in either case and grouping characters are
+// ignored.
+func ParseActivationCode(code string) (*ActivationCode, error) {
+	data, err := decodeCode("activation", code, activationCodeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ActivationCode{
+		PublicKey:     data[:activationKeySize],
+		ProductDigest: data[activationKeySize:],
+	}, nil
+}
+
+// String formats the activation code in groups of four characters, for
+// example "1F6C-9QXA-T3ZE-...".
+func (a *ActivationCode) String() string {
+	return encodeCode("activation", a.bytes())
+}
+
+// IsFor determines whether the activation code was produced for the given
+// product.
+func (a *ActivationCode) IsFor(productID string) bool {
+	productDigest := sha256.Sum256([]byte(productID))
+	return bytes.Equal(a.ProductDigest, productDigest[:activationProductDigestSize])
+}
+
+func (a *ActivationCode) bytes() []byte {
+	return append(append([]byte{}, a.PublicKey...), a.ProductDigest...)
+}
+
+// ActivationCode retrieves the activation code for this machine's key and
+// the KeyManager's product. It should be read to the license's
+// issuer, who will provide a response code with which to activate a
+// locked license.
+func (m *KeyManager) ActivationCode() (*ActivationCode, error) {
+	if m.Product == "" || m.Product == DefaultKeyProduct {
+		return nil, errors.New("expected the key manager to be bound to a product")
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewActivationCode(privKey, m.Product)
+}
+
+// ActivateLicense unlocks a locked license container using the response
+// code provided by its issuer, validates it against the product's root
+// certificate and installs it, as with InstallLicense. The installed
+// container retains its activation, allowing it to be read again later by
+// this machine alone.
+func (m *KeyManager) ActivateLicense(locked []byte, responseCode string, rootCert *x509.Certificate) (*Data, error) {
+	container, err := ParseContainer(locked)
+	if err != nil {
+		return nil, err
+	}
+
+	if container.Payload.Algorithm != LockedPayloadAlgorithm {
+		return nil, errors.New("license is not locked and does not require activation")
+	}
+
+	_, err = container.IsValidFor(rootCert, m.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeCode("response", responseCode, responseCodeSize)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := m.ActivationCode()
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	machineKey, err := activationKey(privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	responseKey, err := ecdh.X25519().NewPublicKey(container.Payload.ResponseKey)
+	if err != nil {
+		return nil, errors.New("the locked license does not carry a valid response key")
+	}
+
+	secret, err := machineKey.ECDH(responseKey)
+	if err != nil {
+		return nil, err
+	}
+
+	unlockKey := xorBytes(response[:unlockKeySize], responseMAC(secret, "mask", code.bytes())[:unlockKeySize])
+	if !hmac.Equal(response[unlockKeySize:], responseMAC(secret, "tag", code.bytes(), unlockKey)[:responseTagSize]) {
+		return nil, errors.New("the response code was not issued for this machine's activation code")
+	}
+
+	activation, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, &privKey.PublicKey, unlockKey, []byte(ActivationKeyLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	container.Payload.Activation = activation
+
+	data, err := container.LicenseFor(privKey, rootCert, m.Product)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Meta == nil || data.Meta.ID == "" {
+		return nil, fmt.Errorf("license does not have an ID and cannot be installed")
+	}
+
+	activated, err := EncodeContainer(container)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.writeLicenseFile(data.Meta.ID, activated)
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// LockLicense will encrypt the license data using a random unlock key,
+// rather than for a specific client, and sign it as with IssueLicense. The
+// resulting container may be shipped with a product and later activated by
+// any single machine using the response code produced by RespondToActivation.
+// Each locked license carries the public half of a random X25519 response
+// key, whose private half is encrypted for the issuer along with the unlock
+// key. The private key must support decryption in order to produce
+// responses.
+func (m *CertManager) LockLicense(data *Data, privKey crypto.Signer, algorithm string) (*Container, error) {
+	if data == nil || data.Meta == nil {
+		return nil, errors.New("expected license metadata to be provided")
+	}
+
+	if data.Meta.Product == "" {
+		data.Meta.Product = m.Product.ID
+	}
+
+	issuerKey, ok := privKey.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("unsupported private key type, required RSA")
+	}
+
+	unlockKey := make([]byte, unlockKeySize)
+	_, err := rand.Read(unlockKey)
+	if err != nil {
+		return nil, err
+	}
+
+	responseKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	c := Container{}
+	err = c.Payload.Lock(data, unlockKey, responseKey, issuerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.SignContainer(&c, privKey, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordLicense(data, privKey, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// RespondToActivation produces the response code which activates a locked
+// license, issued by LockLicense, for the machine which provided the given
+// activation code. The unlock key is masked, and tagged, using the secret
+// shared by the license's response key and the machine's activation key,
+// so that the response code is useless to any other machine and cannot be
+// produced without the issuer's key. The private key must be the one used
+// to lock the license.
+func (m *CertManager) RespondToActivation(locked *Container, code *ActivationCode, privKey crypto.Signer) (string, error) {
+	if locked == nil || locked.Payload.Algorithm != LockedPayloadAlgorithm {
+		return "", errors.New("expected a locked license to be provided")
+	}
+
+	if !code.IsFor(m.Product.ID) {
+		return "", fmt.Errorf("activation code was not produced for product '%s'", m.Product.ID)
+	}
+
+	decrypter, ok := privKey.(crypto.Decrypter)
+	if !ok {
+		return "", errors.New("the signing key cannot be used to decrypt the license's unlock key")
+	}
+
+	secret, err := decrypter.Decrypt(rand.Reader, locked.Payload.Key, &rsa.OAEPOptions{
+		Hash:  crypto.SHA256,
+		Label: []byte(UnlockKeyLabel),
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not recover the license's unlock key: %s", err)
+	}
+
+	if len(secret) != unlockKeySize+activationKeySize {
+		return "", errors.New("the locked license's unlock key is not valid")
+	}
+
+	unlockKey := secret[:unlockKeySize]
+	responseKey, err := ecdh.X25519().NewPrivateKey(secret[unlockKeySize:])
+	if err != nil {
+		return "", err
+	}
+
+	if !bytes.Equal(responseKey.PublicKey().Bytes(), locked.Payload.ResponseKey) {
+		return "", errors.New("the locked license's response key does not match its unlock key")
+	}
+
+	machineKey, err := ecdh.X25519().NewPublicKey(code.PublicKey)
+	if err != nil {
+		return "", errors.New("the activation code does not carry a valid key")
+	}
+
+	shared, err := responseKey.ECDH(machineKey)
+	if err != nil {
+		return "", err
+	}
+
+	response := xorBytes(unlockKey, responseMAC(shared, "mask", code.bytes())[:unlockKeySize])
+	response = append(response, responseMAC(shared, "tag", code.bytes(), unlockKey)[:responseTagSize]...)
+
+	return encodeCode("response", response), nil
+}
+
+// activationKey derives the X25519 key carried by a machine's activation
+// codes from its private key, so that it need not be stored separately.
+func activationKey(privKey *rsa.PrivateKey) (*ecdh.PrivateKey, error) {
+	seed := sha256.Sum256(append([]byte(activationSecretLabel), x509.MarshalPKCS1PrivateKey(privKey)...))
+	return ecdh.X25519().NewPrivateKey(seed[:])
+}
+
+// responseMAC computes an HMAC of the given parts, keyed by the secret a
+// machine shares with a locked license's issuer, and is used both to mask
+// the unlock key and to tag the response code.
+func responseMAC(secret []byte, purpose string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(UnlockKeyLabel + " " + purpose))
+	for _, part := range parts {
+		mac.Write(part)
+	}
+
+	return mac.Sum(nil)
+}
+
+func lockedPayloadKey(unlockKey []byte) []byte {
+	key := sha256.Sum256(unlockKey)
+	return key[:]
+}
+
+func xorBytes(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+
+	return result
+}
+
+func codeChecksum(kind string, data []byte) []byte {
+	checksum := sha256.Sum256(append([]byte(kind), data...))
+	return checksum[:codeChecksumSize]
+}
+
+func encodeCode(kind string, data []byte) string {
+	encoded := codeEncoding.EncodeToString(append(append([]byte{}, data...), codeChecksum(kind, data)...))
+
+	groups := []string{}
+	for i := 0; i < len(encoded); i += codeGroupSize {
+		end := i + codeGroupSize
+		if end > len(encoded) {
+			end = len(encoded)
+		}
+
+		groups = append(groups, encoded[i:end])
+	}
+
+	return strings.Join(groups, "-")
+}
+
+func decodeCode(kind, code string, size int) ([]byte, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case '-', ' ':
+			return -1
+		case 'I', 'L':
+			return '1'
+		case 'O':
+			return '0'
+		default:
+			return r
+		}
+	}, strings.ToUpper(code))
+
+	data, err := codeEncoding.DecodeString(normalized)
+	if err != nil || len(data) != size {
+		return nil, fmt.Errorf("the %s code is not valid", kind)
+	}
+
+	body := data[:size-codeChecksumSize]
+	if !bytes.Equal(data[size-codeChecksumSize:], codeChecksum(kind, body)) {
+		return nil, fmt.Errorf("the %s code is not valid, please check that it was entered correctly", kind)
+	}
+
+	return body, nil
+}
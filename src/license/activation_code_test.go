@@ -0,0 +1,318 @@
+package license
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActivationCodeEncoding(t *testing.T) {
+	code := &ActivationCode{
+		PublicKey:     bytes.Repeat([]byte{1}, activationKeySize),
+		ProductDigest: []byte{7, 8},
+	}
+
+	encoded := code.String()
+	if len(encoded) != 72 || strings.Count(encoded, "-") != 14 {
+		t.Errorf("expected a code of fifteen groups of up to four characters, got '%s'", encoded)
+	}
+
+	parsed, err := ParseActivationCode(strings.ToLower(strings.Replace(encoded, "-", " ", -1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.String() != encoded {
+		t.Errorf("expected the code to round trip, got '%s'", parsed.String())
+	}
+
+	typo := []byte(encoded)
+	if typo[0] == 'A' {
+		typo[0] = 'B'
+	} else {
+		typo[0] = 'A'
+	}
+
+	if _, err := ParseActivationCode(string(typo)); err == nil {
+		t.Error("expected a mistyped code to fail its checksum")
+	}
+
+	if _, err := ParseActivationCode(encoded[:10]); err == nil {
+		t.Error("expected a truncated code to be rejected")
+	}
+}
+
+func TestPhoneActivation(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	vendor := NewKeyManager(machineCode)
+	vendor.Path = testPath
+	vendor.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := vendor.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cm.LockLicense(&Data{
+		Meta: &Metadata{
+			ID:          "shipped",
+			ActivatesOn: time.Now().Add(-time.Hour),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{"seats": 1.0},
+	}, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := EncodeContainer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.License(rootKey, rootCert); err != ErrNotUnlocked {
+		t.Errorf("expected the locked license to be unreadable, got %v", err)
+	}
+
+	client := NewKeyManager(machineCode)
+	client.Path = testPath
+	client.KeySize = 1024
+	client.Product = testProduct.ID
+
+	code, err := client.ActivationCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseActivationCode(code.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := cm.RespondToActivation(c, parsed, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response) != 32 {
+		t.Errorf("expected a response code of seven groups of up to four characters, got '%s'", response)
+	}
+
+	other := NewKeyManager(machineCode)
+	other.Path = testPath
+	other.KeySize = 1024
+	other.Product = testProduct.ID
+	other.Profile = "other"
+
+	if _, err := other.ActivateLicense(locked, response, rootCert); err == nil {
+		t.Error("expected the response code to be rejected by another machine")
+	}
+
+	data, err := client.ActivateLicense(locked, response, rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.ID != "shipped" || data.Payload["seats"] != 1.0 {
+		t.Errorf("expected the activated license to be readable, got %#v", data)
+	}
+
+	installed, err := client.InstalledLicenses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	activated, err := ParseContainer(installed["shipped"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := client.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := activated.License(privKey, rootCert); err != nil {
+		t.Error("expected the installed license to remain readable: ", err)
+	}
+
+	otherKey, err := other.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := activated.License(otherKey, rootCert); err == nil {
+		t.Error("expected the installed license not to be readable by another machine")
+	}
+
+	foreign, err := NewActivationCode(privKey, "other")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cm.RespondToActivation(c, foreign, rootKey); err == nil {
+		t.Error("expected an activation code for another product to be rejected")
+	}
+}
+
+func TestPhoneActivationForgedResponse(t *testing.T) {
+	machineCode := []byte("test")
+	testPath, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	vendor := NewKeyManager(machineCode)
+	vendor.Path = testPath
+	vendor.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := vendor.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cm.LockLicense(&Data{
+		Meta: &Metadata{
+			ID:          "shipped",
+			ActivatesOn: time.Now().Add(-time.Hour),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{},
+	}, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	locked, err := EncodeContainer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewKeyManager(machineCode)
+	client.Path = testPath
+	client.KeySize = 1024
+	client.Product = testProduct.ID
+
+	code, err := client.ActivationCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := cm.RespondToActivation(c, code, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewKeyManager(machineCode)
+	other.Path = testPath
+	other.KeySize = 1024
+	other.Product = testProduct.ID
+	other.Profile = "other"
+
+	otherCode, err := other.ActivationCode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := rootKey.Decrypt(rand.Reader, c.Payload.Key, &rsa.OAEPOptions{
+		Hash:  crypto.SHA256,
+		Label: []byte(UnlockKeyLabel),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlockKey := secret[:unlockKeySize]
+
+	body, err := decodeCode("response", response, responseCodeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(body, unlockKey) {
+		t.Error("expected the response code not to carry the unlock key in the clear")
+	}
+
+	// Even the holder of the unlock key must not be able to produce
+	// response codes without the issuer's response key.
+	forgeryKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ecdh.X25519().NewPublicKey(otherCode.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared, err := forgeryKey.ECDH(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forgedBody := xorBytes(unlockKey, responseMAC(shared, "mask", otherCode.bytes())[:unlockKeySize])
+	forgedBody = append(forgedBody, responseMAC(shared, "tag", otherCode.bytes(), unlockKey)[:responseTagSize]...)
+	forged := encodeCode("response", forgedBody)
+
+	if _, err := other.ActivateLicense(locked, forged, rootCert); err == nil {
+		t.Error("expected a response code which was not produced by the issuer to be rejected")
+	}
+
+	substituted := *c
+	substituted.Payload.ResponseKey = forgeryKey.PublicKey().Bytes()
+	substitutedLocked, err := EncodeContainer(&substituted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := other.ActivateLicense(substitutedLocked, forged, rootCert); err != ErrInvalidSignature {
+		t.Errorf("expected a locked license whose response key was replaced to be rejected, got %v", err)
+	}
+
+	if _, err := other.ActivateLicense(locked, response, rootCert); err == nil {
+		t.Error("expected the response code to be rejected by another machine")
+	}
+
+	if _, err := client.ActivateLicense(locked, response, rootCert); err != nil {
+		t.Error("expected the response code to activate the license for its machine: ", err)
+	}
+}
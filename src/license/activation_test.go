@@ -0,0 +1,158 @@
+package license
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOfflineActivation(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	vendor := NewKeyManager(machineCode)
+	vendor.Path = testPath
+	vendor.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := vendor.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewKeyManager(machineCode)
+	client.Path = testPath
+	client.KeySize = 1024
+	client.Product = testProduct.ID
+
+	_, err = vendor.CreateLicenseRequest("standard")
+	if err == nil {
+		t.Error("expected a request from a key manager without a product to be rejected")
+	}
+
+	reqData, err := client.CreateLicenseRequest("standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := ParseLicenseRequest(reqData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := client.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint, err := KeyFingerprint(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !req.PublicKey.Equal(clientKey) || req.Fingerprint != fingerprint {
+		t.Error("expected the request to carry the machine's public key and fingerprint")
+	}
+
+	if req.Product != testProduct.ID || req.Template != "standard" {
+		t.Errorf("expected the request to be for testing/standard, got %s/%s", req.Product, req.Template)
+	}
+
+	if time.Since(req.ClientTime) > time.Minute {
+		t.Errorf("expected the request to record the client's clock, got %s", req.ClientTime)
+	}
+
+	block, _ := pem.Decode(reqData)
+	block.Bytes[len(block.Bytes)/2] ^= 0x01
+	if _, err := ParseLicenseRequest(pem.EncodeToMemory(block)); err == nil {
+		t.Error("expected a tampered request to be rejected")
+	}
+
+	req.ClientTime = time.Now().Add(-time.Minute)
+	c, err := cm.FulfilLicenseRequest(req, &Data{
+		Meta: &Metadata{
+			ID:          "offline",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{},
+	}, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := EncodeContainer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := client.InstallLicenseResponse(response, rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.LicenseTemplate != "standard" || data.Meta.Product != testProduct.ID {
+		t.Errorf("expected the license to record the requested template, got %#v", data.Meta)
+	}
+
+	if !data.Meta.ActivatesOn.Equal(req.ClientTime) {
+		t.Errorf("expected the license to activate at the client's time, got %s", data.Meta.ActivatesOn)
+	}
+
+	installed, err := client.InstalledLicenses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := installed["offline"]; !ok {
+		t.Error("expected the license to be installed")
+	}
+
+	if _, err := vendor.InstallLicenseResponse(response, rootCert); err == nil {
+		t.Error("expected the response to be rejected by another machine")
+	}
+
+	other := *req
+	other.Product = "other"
+	if _, err := cm.FulfilLicenseRequest(&other, &Data{Meta: &Metadata{}}, rootKey, "sha256"); err == nil {
+		t.Error("expected a request for another product to be rejected")
+	}
+
+	activatesOn := time.Now()
+	skewed := *req
+	skewed.ClientTime = activatesOn.AddDate(-1, 0, 0)
+	skewedData := &Data{
+		Meta: &Metadata{
+			ID:          "skewed",
+			ActivatesOn: activatesOn,
+			ExpiresOn:   activatesOn.Add(time.Hour),
+		},
+		Payload: map[string]interface{}{},
+	}
+
+	if _, err := cm.FulfilLicenseRequest(&skewed, skewedData, rootKey, "sha256"); err != nil {
+		t.Fatal(err)
+	}
+
+	if earliest := activatesOn.Add(-DefaultMaxClockSkew); !skewedData.Meta.ActivatesOn.Equal(earliest) {
+		t.Errorf("expected the license to be backdated by at most %s, got %s", DefaultMaxClockSkew, skewedData.Meta.ActivatesOn)
+	}
+}
@@ -0,0 +1,76 @@
+package license
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"sync"
+)
+
+// BatchRequest describes a single license to be issued by IssueBatch.
+type BatchRequest struct {
+	Data      *Data
+	PublicKey *rsa.PublicKey
+}
+
+// IssueBatch issues a license for each of the given requests, as with
+// IssueLicense, using no more than the given number of concurrent workers.
+// The done callback is called once for each request as it completes, with
+// the request's index and either its container or the error which prevented
+// it from being issued. Calls to done are never made concurrently, and if
+// it returns an error no further requests are issued and that error is
+// returned.
+func (m *CertManager) IssueBatch(requests []*BatchRequest, privKey crypto.Signer, algorithm string, workers int, done func(index int, c *Container, err error) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+
+	indices := make(chan int)
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var failure error
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range indices {
+				req := requests[index]
+
+				var c *Container
+				var err error
+				if req == nil || req.PublicKey == nil {
+					err = errors.New("expected the license's public key to be provided")
+				} else {
+					c, err = m.IssueLicense(req.Data, req.PublicKey, privKey, algorithm)
+				}
+
+				lock.Lock()
+				if failure == nil {
+					failure = done(index, c, err)
+					if failure != nil {
+						close(stop)
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for i := range requests {
+		select {
+		case indices <- i:
+		case <-stop:
+			break dispatch
+		}
+	}
+
+	close(indices)
+	wg.Wait()
+
+	return failure
+}
@@ -0,0 +1,117 @@
+package license
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCertManIssueBatch(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+	cm.Ledger = NewMemoryLedger()
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []*BatchRequest{}
+	for i := 0; i < 10; i++ {
+		requests = append(requests, &BatchRequest{
+			Data: &Data{
+				Meta: &Metadata{
+					ID:          fmt.Sprintf("batch-%d", i),
+					ActivatesOn: time.Now(),
+					ExpiresOn:   time.Now().Add(time.Hour),
+				},
+				Payload: map[string]interface{}{},
+			},
+			PublicKey: clientKey,
+		})
+	}
+
+	requests[3].PublicKey = nil
+
+	issued := map[int]*Container{}
+	failed := map[int]error{}
+	err = cm.IssueBatch(requests, rootKey, "sha256", 4, func(index int, c *Container, err error) error {
+		if err != nil {
+			failed[index] = err
+		} else {
+			issued[index] = c
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(issued) != 9 || len(failed) != 1 || failed[3] == nil {
+		t.Fatalf("expected 9 licenses to be issued and the fourth to fail, got %d issued and %v", len(issued), failed)
+	}
+
+	for index, c := range issued {
+		data, err := c.License(rootKey, rootCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if data.Meta.ID != requests[index].Data.Meta.ID {
+			t.Errorf("expected license %d to have ID '%s', got '%s'", index, requests[index].Data.Meta.ID, data.Meta.ID)
+		}
+	}
+
+	entries, err := cm.Ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 9 {
+		t.Errorf("expected 9 licenses to be recorded in the ledger, got %d", len(entries))
+	}
+
+	calls := 0
+	stop := errors.New("stop")
+	err = cm.IssueBatch(requests, rootKey, "sha256", 2, func(index int, c *Container, err error) error {
+		calls++
+		return stop
+	})
+	if err != stop {
+		t.Errorf("expected the callback's error to be returned, got %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected no further callbacks after an error, got %d", calls)
+	}
+}
@@ -0,0 +1,161 @@
+package license
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+)
+
+// BundledLicenseType is used to armour installed license containers when
+// they are included within a machine bundle.
+const BundledLicenseType = "LITHIUM BUNDLED LICENSE"
+
+// ExportBundle produces a passphrase protected bundle containing this
+// machine's keypair and every license installed for it. The bundle may
+// be imported on another machine using ImportBundle, allowing a machine
+// identity to be moved to new hardware.
+func (m *KeyManager) ExportBundle(passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("expected a passphrase to protect the bundle")
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	pubData, err := ioutil.ReadFile(m.PublicKeyPath())
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedPrivateKey, err := x509.EncryptPEMBlock(
+		rand.Reader,
+		PrivateKeyType,
+		x509.MarshalPKCS1PrivateKey(privKey),
+		passphrase,
+		x509.PEMCipherAES256,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	d := pem.EncodeToMemory(encryptedPrivateKey)
+	d = append(d, pubData...)
+
+	licenses, err := m.InstalledLicenses()
+	if err != nil {
+		return nil, err
+	}
+
+	for id, license := range licenses {
+		d = append(d, pem.EncodeToMemory(&pem.Block{
+			Type: BundledLicenseType,
+			Headers: map[string]string{
+				"id": id,
+			},
+			Bytes: license,
+		})...)
+	}
+
+	return d, nil
+}
+
+// ImportBundle will install the keypair and licenses contained within a
+// bundle produced by ExportBundle, replacing this machine's existing
+// keypair. The private key is re-encrypted using this KeyManager's
+// MachineCode.
+func (m *KeyManager) ImportBundle(bundle []byte, passphrase []byte) error {
+	if len(m.MachineCode) == 0 {
+		return ErrNoMachineCode
+	}
+
+	var privBlock, pubBlock *pem.Block
+	licenses := map[string][]byte{}
+
+	d := bundle
+	for {
+		block, rest := pem.Decode(d)
+		if block == nil {
+			break
+		}
+
+		d = rest
+
+		switch block.Type {
+		case PrivateKeyType:
+			privBlock = block
+		case PublicKeyType:
+			pubBlock = block
+		case BundledLicenseType:
+			id := block.Headers["id"]
+			if id == "" {
+				return errors.New("bundled license did not specify its ID")
+			}
+
+			licenses[id] = block.Bytes
+		}
+	}
+
+	if privBlock == nil || pubBlock == nil {
+		return errors.New("expected bundle to contain a keypair")
+	}
+
+	if !x509.IsEncryptedPEMBlock(privBlock) {
+		return errors.New("expected bundled private key to be encrypted")
+	}
+
+	privData, err := x509.DecryptPEMBlock(privBlock, passphrase)
+	if err != nil {
+		return err
+	}
+
+	priv, err := x509.ParsePKCS1PrivateKey(privData)
+	if err != nil {
+		return err
+	}
+
+	pub, err := ParsePublicKey(pem.EncodeToMemory(pubBlock))
+	if err != nil {
+		return err
+	}
+
+	if pub.N.Cmp(priv.N) != 0 || pub.E != priv.E {
+		return errors.New("bundled public key does not match the bundled private key")
+	}
+
+	encryptedPrivateKey, err := x509.EncryptPEMBlock(
+		rand.Reader,
+		PrivateKeyType,
+		privData,
+		m.MachineCode,
+		x509.PEMCipherAES256,
+	)
+	if err != nil {
+		return err
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	err = m.writeKeyFile(m.PrivateKeyPath(), pem.EncodeToMemory(encryptedPrivateKey))
+	if err != nil {
+		return err
+	}
+
+	err = m.writeKeyFile(m.PublicKeyPath(), pem.EncodeToMemory(pubBlock))
+	if err != nil {
+		return err
+	}
+
+	for id, license := range licenses {
+		err = m.writeLicenseFile(id, license)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
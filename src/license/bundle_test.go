@@ -0,0 +1,132 @@
+package license
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExportImportBundle(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager([]byte("old machine"))
+	km.Path = testPath
+	km.KeySize = 1024
+	km.Product = "old"
+
+	key, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewCertManager(testProduct)
+	cert, err := cm.CreateRoot(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Container{
+		Certificates: []*x509.Certificate{cert},
+	}
+
+	err = c.SetLicense(&Data{
+		Meta: &Metadata{
+			ID:          "license1",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{},
+	}, &key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Sign(key, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	containerData, err := EncodeContainer(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := km.InstallLicense(containerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.ID != "license1" {
+		t.Errorf("expected installed license ID to be 'license1', got '%s'", data.Meta.ID)
+	}
+
+	bundle, err := km.ExportBundle([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nm := NewKeyManager([]byte("new machine"))
+	nm.Path = testPath
+	nm.KeySize = 1024
+	nm.Product = "new"
+
+	err = nm.ImportBundle(bundle, []byte("wrong"))
+	if err == nil {
+		t.Error("expected import with the wrong passphrase to fail")
+	}
+
+	err = nm.ImportBundle(bundle, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, err := nm.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(key, newKey) {
+		t.Error("expected the imported key to match the exported key")
+	}
+
+	licenses, err := nm.InstalledLicenses()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(licenses["license1"], containerData) {
+		t.Error("expected the installed license to be imported")
+	}
+
+	nm.MachineCode = []byte("old machine")
+	_, err = nm.GetPrivateKey()
+	if err == nil {
+		t.Error("expected the imported key to be protected by the new machine code")
+	}
+}
+
+func TestExportBundleRequiresPassphrase(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager([]byte("test"))
+	km.Path = testPath
+	km.KeySize = 1024
+
+	_, err = km.ExportBundle(nil)
+	if err == nil {
+		t.Error("expected an error when no passphrase was provided")
+	}
+}
@@ -1,10 +1,12 @@
 package license
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -18,6 +20,21 @@ import (
 type CertManager struct {
 	Path    string
 	Product *Product
+
+	// Ledger, if provided, allocates serial numbers for and records every
+	// certificate and license issued by this CertManager.
+	Ledger Ledger
+
+	// Packs, if provided, tracks the number of licenses issued from each
+	// template of a license pack by IssueSublicense.
+	Packs PackCounter
+
+	// MaxClockSkew is the furthest that FulfilLicenseRequest will backdate
+	// a license to match the clock of the requesting machine, defaulting
+	// to DefaultMaxClockSkew.
+	MaxClockSkew time.Duration
+
+	unlocked *rsa.PrivateKey
 }
 
 // NewCertManager is responsible for creating a new certificate manager
@@ -34,22 +51,70 @@ func NewCertManager(prod *Product) *CertManager {
 }
 
 // SetLocal will update the stored local certificate to match the
-// certificate provided.
+// certificate provided. If the certificate was issued by a certificate
+// within the existing chain, that portion of the chain is retained.
 func (m *CertManager) SetLocal(cert *x509.Certificate) error {
-	f, err := os.Create(m.getCertificateFilePath(fmt.Sprintf("%s.crt", m.Product.ID)))
+	chain, err := m.GetChain()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.Write(cert.Raw)
-	return err
+	if chain == nil {
+		chain = &Chain{}
+	}
+
+	parents := []*x509.Certificate{}
+	for i := len(chain.Certificates) - 1; i >= 0; i-- {
+		if !cert.Equal(chain.Certificates[i]) && cert.CheckSignatureFrom(chain.Certificates[i]) == nil {
+			parents = chain.Certificates[:i+1]
+			break
+		}
+	}
+
+	return m.SetChain(&Chain{
+		Certificates: append(parents, cert),
+		KeyRef:       chain.KeyRef,
+	})
 }
 
 // GetLocal retrieves the certificate used to sign derivative
 // licenses for the current server.
 func (m *CertManager) GetLocal() (*x509.Certificate, error) {
-	f, err := os.Open(m.getCertificateFilePath(fmt.Sprintf("%s.crt", m.Product.ID)))
+	chain, err := m.GetChain()
+	if err != nil || chain == nil {
+		return nil, err
+	}
+
+	return chain.Leaf(), nil
+}
+
+// SetChain will persist the full certificate chain, from the trusted root
+// to the local certificate, along with the reference to the local
+// certificate's private key.
+func (m *CertManager) SetChain(chain *Chain) error {
+	err := chain.Verify()
+	if err != nil {
+		return err
+	}
+
+	data, err := EncodeChain(chain)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.Path, os.ModePerm|os.ModeDir)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(m.ChainPath(), data, 0644)
+}
+
+// GetChain retrieves the full certificate chain for the local certificate.
+// Chains may be stored in either PEM or DER form. If no chain has been
+// stored, nil is returned.
+func (m *CertManager) GetChain() (*Chain, error) {
+	data, err := ioutil.ReadFile(m.ChainPath())
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -57,26 +122,60 @@ func (m *CertManager) GetLocal() (*x509.Certificate, error) {
 
 		return nil, err
 	}
-	defer f.Close()
 
-	certData, err := ioutil.ReadAll(f)
+	return ParseChain(data)
+}
+
+// ChainPath returns the path at which the local certificate chain is
+// stored.
+func (m *CertManager) ChainPath() string {
+	return m.getCertificateFilePath(fmt.Sprintf("%s.crt", m.Product.ID))
+}
+
+// SignContainer will sign a license container using the provided private
+// key, which must match the local certificate, and attach the full chain
+// of certificates needed to validate that signature.
+func (m *CertManager) SignContainer(c *Container, privKey crypto.Signer, algorithm string) error {
+	chain, err := m.GetChain()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	cert, err := x509.ParseCertificate(certData)
+	if chain == nil {
+		return errors.New("no certificate available to sign license")
+	}
+
+	if pub, ok := privKey.Public().(interface {
+		Equal(crypto.PublicKey) bool
+	}); !ok || !pub.Equal(chain.Leaf().PublicKey) {
+		return errors.New("private key does not match the local certificate")
+	}
+
+	binding, err := chainBinding(chain.Certificates)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return cert, nil
+	if binding != nil && binding.ProductID != m.Product.ID {
+		return fmt.Errorf("the local certificate is bound to product '%s'", binding.ProductID)
+	}
+
+	err = c.Sign(privKey, algorithm)
+	if err != nil {
+		return err
+	}
+
+	c.Certificates = chain.Certificates
+	return nil
 }
 
 // Sign is responsible for signing a provided certificate
 // using the current product's certificate and private key. This then
 // enables consumers of the resulting certificate to trace the authenticity
 // of that certificate back to a single root certificate.
-func (m *CertManager) Sign(csr *x509.Certificate, privKey interface{}) (*x509.Certificate, error) {
+// The private key may be any crypto.Signer, including one backed by a
+// hardware token.
+func (m *CertManager) Sign(csr *x509.Certificate, privKey crypto.Signer) (*x509.Certificate, error) {
 	ownCert, err := m.GetLocal()
 	if err != nil {
 		return nil, err
@@ -90,7 +189,12 @@ func (m *CertManager) Sign(csr *x509.Certificate, privKey interface{}) (*x509.Ce
 		return nil, errors.New("no certificate available to sign request")
 	}
 
-	serialNumber, err := rand.Int(rand.Reader, big.NewInt(0xffffffff))
+	issuer, err := KeyFingerprint(ownCert.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := m.reserveSerial(issuer)
 	if err != nil {
 		return nil, err
 	}
@@ -106,18 +210,145 @@ func (m *CertManager) Sign(csr *x509.Certificate, privKey interface{}) (*x509.Ce
 		return nil, err
 	}
 
+	err = m.recordCertificate(issuer, cert)
+	if err != nil {
+		return nil, err
+	}
+
 	return cert, nil
 }
 
+// IssueLicense will encrypt the license data for the holder of the given
+// public key and sign it using the provided private key, which must match
+// the local certificate. The resulting container includes the full chain
+// of certificates needed to validate it. If a ledger has been configured,
+// the license is recorded within it.
+func (m *CertManager) IssueLicense(data *Data, pubKey *rsa.PublicKey, privKey crypto.Signer, algorithm string) (*Container, error) {
+	if data == nil || data.Meta == nil {
+		return nil, errors.New("expected license metadata to be provided")
+	}
+
+	if data.Meta.Product == "" {
+		data.Meta.Product = m.Product.ID
+	}
+
+	c := Container{}
+	err := c.SetLicense(data, pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.SignContainer(&c, privKey, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	subjectKey, err := KeyFingerprint(pubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordLicense(data, privKey, subjectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// recordLicense records an issued license within the ledger, if one has
+// been configured.
+func (m *CertManager) recordLicense(data *Data, privKey crypto.Signer, subjectKey string) error {
+	if m.Ledger == nil {
+		return nil
+	}
+
+	issuer, err := KeyFingerprint(privKey.Public())
+	if err != nil {
+		return err
+	}
+
+	serialNumber, err := m.Ledger.ReserveSerial(issuer)
+	if err != nil {
+		return err
+	}
+
+	payloadHash, err := PayloadDigest(data.Payload)
+	if err != nil {
+		return err
+	}
+
+	return m.Ledger.Record(&LedgerEntry{
+		Kind:        LedgerLicense,
+		Issuer:      issuer,
+		Serial:      serialNumber.Text(16),
+		LicenseID:   data.Meta.ID,
+		SubjectKey:  subjectKey,
+		NotBefore:   data.Meta.ActivatesOn,
+		NotAfter:    data.Meta.ExpiresOn,
+		PayloadHash: hex.EncodeToString(payloadHash),
+		IssuedAt:    time.Now(),
+	})
+}
+
+func (m *CertManager) recordCertificate(issuer string, cert *x509.Certificate) error {
+	if m.Ledger == nil {
+		return nil
+	}
+
+	subjectKey, err := KeyFingerprint(cert.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	return m.Ledger.Record(&LedgerEntry{
+		Kind:       LedgerCertificate,
+		Issuer:     issuer,
+		Serial:     cert.SerialNumber.Text(16),
+		LicenseID:  cert.Subject.SerialNumber,
+		SubjectKey: subjectKey,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		IssuedAt:   time.Now(),
+	})
+}
+
+func (m *CertManager) reserveSerial(issuer string) (*big.Int, error) {
+	if m.Ledger != nil {
+		return m.Ledger.ReserveSerial(issuer)
+	}
+
+	for {
+		serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+		if err != nil || serialNumber.Sign() != 0 {
+			return serialNumber, err
+		}
+	}
+}
+
 // Prepare is responsible for preparing an x509 certificate to match
-// a specific license's constraints.
-func (m *CertManager) Prepare(csr *x509.CertificateRequest, license *Data) *x509.Certificate {
+// a specific license's constraints. The request's signature is verified
+// to ensure that the requester is in possession of the private key
+// matching the public key which will be certified.
+func (m *CertManager) Prepare(csr *x509.CertificateRequest, license *Data) (*x509.Certificate, error) {
+	if csr == nil {
+		return nil, errors.New("expected a certificate request to be provided")
+	}
+
+	if license == nil || license.Meta == nil {
+		return nil, errors.New("expected license metadata to be provided")
+	}
+
+	err := csr.CheckSignature()
+	if err != nil {
+		return nil, err
+	}
+
 	cert := x509.Certificate{
 		Subject:               csr.Subject,
 		DNSNames:              csr.DNSNames,
 		Issuer:                m.getIssuer(),
 		BasicConstraintsValid: true,
-		MaxPathLen:            128,
 		IsCA:                  false,
 		NotBefore:             license.Meta.ActivatesOn,
 		NotAfter:              license.Meta.ExpiresOn,
@@ -126,44 +357,91 @@ func (m *CertManager) Prepare(csr *x509.CertificateRequest, license *Data) *x509
 		PublicKeyAlgorithm:    csr.PublicKeyAlgorithm,
 	}
 
+	digest, err := PayloadDigest(license.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	pack, err := NewPackBindings(license.Meta.Pack)
+	if err != nil {
+		return nil, err
+	}
+
+	binding := LicenseBinding{
+		ProductID:     m.Product.ID,
+		LicenseID:     license.Meta.ID,
+		Template:      license.Meta.Template,
+		PayloadDigest: digest,
+		Pack:          pack,
+	}
+
+	ext, err := binding.Extension()
+	if err != nil {
+		return nil, err
+	}
+
+	cert.ExtraExtensions = append(cert.ExtraExtensions, ext)
+
 	if license.Meta.Pack != nil && len(license.Meta.Pack) > 0 {
 		cert.KeyUsage = cert.KeyUsage | x509.KeyUsageCertSign
 		cert.IsCA = true
+		cert.MaxPathLen = 128
 	}
 
-	cert.Subject.CommonName = fmt.Sprintf("%s (%s)", m.Product.Name, m.Product.ID)
-	cert.Subject.SerialNumber = license.Meta.ID
-	cert.Subject.Organization = []string{m.Product.Organization}
-	cert.Subject.OrganizationalUnit = []string{"Lithium Licensing"}
+	productSubject := m.Product.subject(license.Meta.ID)
+	cert.Subject.CommonName = productSubject.CommonName
+	cert.Subject.SerialNumber = productSubject.SerialNumber
+	cert.Subject.Organization = productSubject.Organization
+	cert.Subject.OrganizationalUnit = productSubject.OrganizationalUnit
+	cert.Subject.Locality = productSubject.Locality
+	cert.Subject.Province = productSubject.Province
+	cert.Subject.Country = productSubject.Country
 
-	return &cert
+	return &cert, nil
 }
 
-// CreateRoot will create a new, self-signed, root certificate.
-func (m *CertManager) CreateRoot(privKey *rsa.PrivateKey) (*x509.Certificate, error) {
+// CreateRoot will create a new, self-signed, root certificate. The
+// private key may be any crypto.Signer, including one backed by a
+// hardware token. The certificate's names, lifetime and key usage are
+// determined by the product.
+func (m *CertManager) CreateRoot(privKey crypto.Signer) (*x509.Certificate, error) {
+	keyUsage, err := m.Product.RootKeyUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	uris, err := m.Product.RootURIs()
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := (&LicenseBinding{ProductID: m.Product.ID}).Extension()
+	if err != nil {
+		return nil, err
+	}
+
 	template := x509.Certificate{
-		Subject: pkix.Name{
-			CommonName:         fmt.Sprintf("%s (%s)", m.Product.Name, m.Product.ID),
-			SerialNumber:       "Root Certificate",
-			Organization:       []string{m.Product.Organization},
-			OrganizationalUnit: []string{"Lithium Licensing"},
-		},
+		Subject:               m.Product.subject("Root Certificate"),
 		Issuer:                m.getIssuer(),
 		BasicConstraintsValid: true,
-		IsCA:               true,
-		MaxPathLen:         128,
-		SerialNumber:       big.NewInt(1),
-		NotBefore:          time.Now(),
-		NotAfter:           time.Now().Add(100 * 365 * 24 * time.Hour),
-		KeyUsage:           x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature | x509.KeyUsageDataEncipherment | x509.KeyUsageKeyEncipherment | x509.KeyUsageContentCommitment,
-		SignatureAlgorithm: x509.SHA256WithRSA,
-		DNSNames:           []string{"localhost"},
-		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		IsCA:                  true,
+		MaxPathLen:            128,
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(m.Product.RootValidity()),
+		KeyUsage:              keyUsage,
+		SignatureAlgorithm:    x509.SHA256WithRSA,
+		DNSNames:              m.Product.DNSNames,
+		URIs:                  uris,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		ExtraExtensions:       []pkix.Extension{binding},
 	}
 
-	err := privKey.Validate()
-	if err != nil {
-		return nil, err
+	if rsaKey, ok := privKey.(*rsa.PrivateKey); ok {
+		err := rsaKey.Validate()
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	certData, err := x509.CreateCertificate(rand.Reader, &template, &template, privKey.Public(), privKey)
@@ -175,13 +453,7 @@ func (m *CertManager) CreateRoot(privKey *rsa.PrivateKey) (*x509.Certificate, er
 }
 
 func (m *CertManager) getIssuer() pkix.Name {
-	return pkix.Name{
-		CommonName:         "Sierra Softworks Lithium License Protocol",
-		Organization:       []string{"Sierra Softworks"},
-		OrganizationalUnit: []string{"Lithium Licensing"},
-		Province:           []string{"Western Cape"},
-		Country:            []string{"South Africa"},
-	}
+	return m.Product.issuer()
 }
 
 func (m *CertManager) getCertificateFilePath(file string) string {
@@ -18,7 +18,6 @@ var testProduct = &Product{
 }
 
 func TestCertManCreateRoot(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -29,6 +28,7 @@ func TestCertManCreateRoot(t *testing.T) {
 
 	km := NewKeyManager(machineCode)
 	km.Path = testPath
+	km.KeySize = 1024
 	cm := NewCertManager(testProduct)
 	cm.Path = testPath
 
@@ -56,7 +56,6 @@ func TestCertManCreateRoot(t *testing.T) {
 }
 
 func TestCertManPrepareCSR(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -67,6 +66,7 @@ func TestCertManPrepareCSR(t *testing.T) {
 
 	km := NewKeyManager(machineCode)
 	km.Path = testPath
+	km.KeySize = 1024
 	cm := NewCertManager(testProduct)
 	cm.Path = testPath
 
@@ -100,13 +100,16 @@ func TestCertManPrepareCSR(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cert := cm.Prepare(csr, &Data{
+	cert, err := cm.Prepare(csr, &Data{
 		Meta: &Metadata{
 			ID:          "test",
 			ActivatesOn: testTime,
 			ExpiresOn:   testTime,
 		},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	if cert.Subject.SerialNumber != "test" {
 		t.Errorf("expected SN='test', got '%s'", cert.Subject.SerialNumber)
@@ -114,7 +117,6 @@ func TestCertManPrepareCSR(t *testing.T) {
 }
 
 func TestCertManSignCert(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -125,6 +127,7 @@ func TestCertManSignCert(t *testing.T) {
 
 	km := NewKeyManager(machineCode)
 	km.Path = testPath
+	km.KeySize = 1024
 	cm := NewCertManager(testProduct)
 	cm.Path = testPath
 
@@ -178,13 +181,16 @@ func TestCertManSignCert(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cert := cm.Prepare(csr, &Data{
+	cert, err := cm.Prepare(csr, &Data{
 		Meta: &Metadata{
 			ID:          "test",
 			ActivatesOn: testTime,
 			ExpiresOn:   testTime,
 		},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	signedCert, err := cm.Sign(cert, key)
 	if err != nil {
@@ -199,4 +205,64 @@ func TestCertManSignCert(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	binding, err := ParseLicenseBinding(signedCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if binding == nil || binding.ProductID != testProduct.ID || binding.LicenseID != "test" {
+		t.Errorf("expected certificate to be bound to license 'test' of '%s', got %+v", testProduct.ID, binding)
+	}
+}
+
+func TestCertManPrepareRejectsForgedCSR(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	csrData, err := km.CreateCertificateRequest(pkix.Name{CommonName: "machine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := ParseCertificateRequest(csrData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	license := &Data{
+		Meta: &Metadata{
+			ID:          "test",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+	}
+
+	_, err = cm.Prepare(csr, license)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "other"
+	otherKey, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr.PublicKey = otherKey
+	_, err = cm.Prepare(csr, license)
+	if err == nil {
+		t.Error("expected a request for another party's public key to be rejected")
+	}
 }
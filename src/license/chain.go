@@ -0,0 +1,170 @@
+package license
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChainKeyHeader is the PEM header, on the first certificate of an encoded
+// chain, which references the private key matching the chain's final
+// certificate.
+const ChainKeyHeader = "key"
+
+// pemCertificateType is the standard PEM type used for certificates by
+// tools like OpenSSL, which is accepted in addition to CertificateType.
+const pemCertificateType = "CERTIFICATE"
+
+// Chain is an ordered list of certificates, starting with a trusted root
+// and ending with the certificate used for signing. Each certificate is
+// signed by the one preceding it.
+type Chain struct {
+	Certificates []*x509.Certificate
+
+	// KeyRef identifies the private key matching the final certificate
+	// in the chain, for example the name of a key file or the label of a
+	// key held within a hardware token.
+	KeyRef string
+}
+
+// Root returns the first certificate in the chain, or nil if the chain
+// is empty.
+func (c *Chain) Root() *x509.Certificate {
+	if len(c.Certificates) == 0 {
+		return nil
+	}
+
+	return c.Certificates[0]
+}
+
+// Leaf returns the final certificate in the chain, or nil if the chain
+// is empty.
+func (c *Chain) Leaf() *x509.Certificate {
+	if len(c.Certificates) == 0 {
+		return nil
+	}
+
+	return c.Certificates[len(c.Certificates)-1]
+}
+
+// Verify ensures that every certificate in the chain is signed by the
+// certificate which precedes it, and that the chain is currently valid
+// according to the constraints of its certificates, as with VerifyChain.
+func (c *Chain) Verify() error {
+	return VerifyChain(c.Certificates, time.Now())
+}
+
+// VerifyChain ensures that every certificate in a chain is signed by the
+// certificate which precedes it, the first of which is trusted. Every
+// certificate must be valid at the given time, and those which issue
+// others must be permitted to do so by their basic constraints, key usage,
+// path length and name constraints.
+func VerifyChain(certs []*x509.Certificate, at time.Time) error {
+	if len(certs) == 0 {
+		return errors.New("expected at least one certificate to be present")
+	}
+
+	for i := 1; i < len(certs); i++ {
+		err := certs[i].CheckSignatureFrom(certs[i-1])
+		if err != nil {
+			return fmt.Errorf("certificate %d was not issued by certificate %d: %s", i, i-1, err)
+		}
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(certs[0])
+
+	intermediates := x509.NewCertPool()
+	for i := 1; i < len(certs)-1; i++ {
+		intermediates.AddCert(certs[i])
+	}
+
+	_, err := certs[len(certs)-1].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("the certificate chain is not valid: %s", err)
+	}
+
+	return nil
+}
+
+// EncodeChain will encode a certificate chain as a sequence of PEM blocks,
+// recording the chain's key reference on the first block.
+func EncodeChain(chain *Chain) ([]byte, error) {
+	if chain == nil || len(chain.Certificates) == 0 {
+		return nil, errors.New("expected at least one certificate to be present")
+	}
+
+	d := []byte{}
+	for i, cert := range chain.Certificates {
+		block := &pem.Block{
+			Type:    CertificateType,
+			Headers: map[string]string{},
+			Bytes:   cert.Raw,
+		}
+
+		if i == 0 && chain.KeyRef != "" {
+			block.Headers[ChainKeyHeader] = chain.KeyRef
+		}
+
+		d = append(d, pem.EncodeToMemory(block)...)
+	}
+
+	return d, nil
+}
+
+// ParseChain will parse a certificate chain from either a sequence of PEM
+// encoded certificates or one or more concatenated DER certificates.
+func ParseChain(data []byte) (*Chain, error) {
+	chain := Chain{
+		Certificates: []*x509.Certificate{},
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		certs, err := x509.ParseCertificates(data)
+		if err != nil {
+			return nil, err
+		}
+
+		chain.Certificates = certs
+		return &chain, nil
+	}
+
+	d := data
+	for {
+		block, rest := pem.Decode(d)
+		if block == nil {
+			break
+		}
+
+		d = rest
+
+		if block.Type != CertificateType && block.Type != pemCertificateType {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyRef, ok := block.Headers[ChainKeyHeader]; ok && len(chain.Certificates) == 0 {
+			chain.KeyRef = keyRef
+		}
+
+		chain.Certificates = append(chain.Certificates, cert)
+	}
+
+	if len(chain.Certificates) == 0 {
+		return nil, errors.New("no certificates were present in the chain")
+	}
+
+	return &chain, nil
+}
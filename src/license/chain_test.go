@@ -0,0 +1,199 @@
+package license
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChainEncoding(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+
+	key, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCert, err := cm.CreateIntermediate(rootCert, key, key.Public(), &IntermediateOptions{
+		Name: "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := EncodeChain(&Chain{
+		Certificates: []*x509.Certificate{rootCert, serverCert},
+		KeyRef:       "testing.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := ParseChain(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain.Certificates) != 2 {
+		t.Fatalf("expected 2 certificates in the chain, got %d", len(chain.Certificates))
+	}
+
+	if !chain.Root().Equal(rootCert) || !chain.Leaf().Equal(serverCert) {
+		t.Error("expected chain to retain the order of its certificates")
+	}
+
+	if chain.KeyRef != "testing.key" {
+		t.Errorf("expected key reference to be 'testing.key', got '%s'", chain.KeyRef)
+	}
+
+	err = chain.Verify()
+	if err != nil {
+		t.Error(err)
+	}
+
+	derChain, err := ParseChain(append(append([]byte{}, rootCert.Raw...), serverCert.Raw...))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(derChain.Certificates) != 2 {
+		t.Errorf("expected 2 certificates in the DER chain, got %d", len(derChain.Certificates))
+	}
+
+	opensslChain, err := ParseChain(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: rootCert.Raw,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !opensslChain.Leaf().Equal(rootCert) {
+		t.Error("expected standard PEM certificates to be accepted")
+	}
+}
+
+func TestCertManChainStore(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetChain(&Chain{
+		Certificates: []*x509.Certificate{rootCert},
+		KeyRef:       "testing.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "server"
+	serverKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCert, err := cm.CreateIntermediate(rootCert, rootKey, serverKey.Public(), &IntermediateOptions{
+		Name: "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(serverCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain, err := cm.GetChain()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain.Certificates) != 2 {
+		t.Fatalf("expected the root certificate to be retained in the chain, got %d certificates", len(chain.Certificates))
+	}
+
+	if chain.KeyRef != "testing.key" {
+		t.Errorf("expected key reference to be retained, got '%s'", chain.KeyRef)
+	}
+
+	local, err := cm.GetLocal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !local.Equal(serverCert) {
+		t.Error("expected the local certificate to be the server certificate")
+	}
+
+	c := Container{}
+	err = c.SetLicense(&Data{
+		Meta: &Metadata{
+			ID:          "0",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now(),
+		},
+		Payload: map[string]interface{}{},
+	}, &serverKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SignContainer(&c, rootKey, "sha256")
+	if err == nil {
+		t.Error("expected signing with a key which does not match the local certificate to fail")
+	}
+
+	err = cm.SignContainer(&c, serverKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(c.Certificates) != 2 {
+		t.Fatalf("expected the container to include the full chain, got %d certificates", len(c.Certificates))
+	}
+
+	isValid, err := c.IsValid(rootCert)
+	if !isValid {
+		t.Error("expected container to be valid: ", err)
+	}
+}
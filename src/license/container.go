@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 // LicenseKeyType is used to armour the Lithium license encryption key after it
@@ -19,6 +20,11 @@ const LicenseKeyType = "LITHIUM LICENSE KEY"
 // LicenseType is used to armour the Lithium license data when encrypted and encoded.
 const LicenseType = "LITHIUM LICENSE"
 
+// LicenseActivationType is used to armour the activation of a locked license,
+// the unlock key recovered from the response code provided by the license's
+// issuer, encrypted for the activated machine.
+const LicenseActivationType = "LITHIUM LICENSE ACTIVATION"
+
 // SignatureType is used to armour the Lithium license data's signature data.
 const SignatureType = "LITHIUM SIGNATURE"
 
@@ -31,6 +37,14 @@ const CertificateType = "LITHIUM CERTIFICATE"
 // encryption layer.
 const EncryptedLicenseLabel = "Lithium License Encryption Key"
 
+// ErrUntrustedRoot is returned by Container.IsValid when the container's
+// certificate chain does not begin with the trusted root certificate.
+var ErrUntrustedRoot = errors.New("expected first certificate in list to match known root")
+
+// ErrInvalidSignature is returned by Container.IsValid when the license
+// data does not match its signature.
+var ErrInvalidSignature = errors.New("signature did not match the expected signed value")
+
 // Container represents
 type Container struct {
 	Payload      EncryptedPayload
@@ -54,15 +68,28 @@ func EncodeContainer(container *Container) ([]byte, error) {
 		Bytes:   container.Payload.Key,
 	})...)
 
+	headers := map[string]string{
+		"algorithm": container.Payload.Algorithm,
+		"iv":        base64.StdEncoding.EncodeToString(container.Payload.IV),
+	}
+
+	if container.Payload.ResponseKey != nil {
+		headers["responseKey"] = base64.StdEncoding.EncodeToString(container.Payload.ResponseKey)
+	}
+
 	d = append(d, pem.EncodeToMemory(&pem.Block{
-		Type: LicenseType,
-		Headers: map[string]string{
-			"algorithm": container.Payload.Algorithm,
-			"iv":        base64.StdEncoding.EncodeToString(container.Payload.IV),
-		},
-		Bytes: container.Payload.Data,
+		Type:    LicenseType,
+		Headers: headers,
+		Bytes:   container.Payload.Data,
 	})...)
 
+	if container.Payload.Activation != nil {
+		d = append(d, pem.EncodeToMemory(&pem.Block{
+			Type:  LicenseActivationType,
+			Bytes: container.Payload.Activation,
+		})...)
+	}
+
 	if container.Signature == nil {
 		return nil, errors.New("no signature has been provided for the license data")
 	}
@@ -120,6 +147,16 @@ func ParseContainer(licenseData []byte) (*Container, error) {
 
 			c.Payload.IV = iv
 
+			if responseKey, ok := block.Headers["responseKey"]; ok {
+				c.Payload.ResponseKey, err = base64.StdEncoding.DecodeString(responseKey)
+				if err != nil {
+					return nil, err
+				}
+			}
+
+		case LicenseActivationType:
+			c.Payload.Activation = block.Bytes
+
 		case SignatureType:
 			algorithm, exists := block.Headers["algorithm"]
 			if !exists {
@@ -149,9 +186,47 @@ func ParseContainer(licenseData []byte) (*Container, error) {
 }
 
 // License will extract and decode the license data from the encrypted license block
-// in this container.
+// in this container, once the container has been validated using IsValid. The license
+// must name the product its signing certificate is bound to, which must be the product
+// the root certificate is bound to. Applications which share a root certificate between
+// several products should use LicenseFor instead.
 func (c *Container) License(privKey *rsa.PrivateKey, rootCert *x509.Certificate) (*Data, error) {
-	isValid, err := c.IsValid(rootCert)
+	rootBinding, err := ParseLicenseBinding(rootCert)
+	if err != nil {
+		return nil, err
+	}
+
+	if rootBinding != nil {
+		return c.LicenseFor(privKey, rootCert, rootBinding.ProductID)
+	}
+
+	binding, err := c.verify(rootCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var d Data
+	err = c.Payload.Decrypt(&d, privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if binding != nil {
+		err = binding.Permits(&d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &d, nil
+}
+
+// LicenseFor will extract and decode the license data from the encrypted license
+// block in this container, once the container has been validated for the given
+// product using IsValidFor. The license must name that product and be permitted by
+// the license binding of the certificate which signed it.
+func (c *Container) LicenseFor(privKey *rsa.PrivateKey, rootCert *x509.Certificate, productID string) (*Data, error) {
+	isValid, err := c.IsValidFor(rootCert, productID)
 	if !isValid {
 		return nil, err
 	}
@@ -162,6 +237,16 @@ func (c *Container) License(privKey *rsa.PrivateKey, rootCert *x509.Certificate)
 		return nil, err
 	}
 
+	binding, err := chainBinding(c.Certificates)
+	if err != nil {
+		return nil, err
+	}
+
+	err = binding.Permits(&d)
+	if err != nil {
+		return nil, err
+	}
+
 	return &d, nil
 }
 
@@ -174,19 +259,27 @@ func (c *Container) SetLicense(data *Data, pubKey *rsa.PublicKey) error {
 }
 
 // Sign will populate the signature structure with the correct signature and algorithm
-// for the license data provided.
-func (c *Container) Sign(privateKey *rsa.PrivateKey, algorithm string) error {
+// for the license data provided. The private key may be any RSA crypto.Signer, allowing
+// licenses to be signed by keys held within hardware tokens.
+func (c *Container) Sign(privateKey crypto.Signer, algorithm string) error {
 	hash, err := hashByName(algorithm)
 	if err != nil {
 		return err
 	}
 
-	hashedData, err := computeHash(c.Payload.Data, hash)
+	hashedData, err := computeHash(c.Payload.signedData(), hash)
 	if err != nil {
 		return err
 	}
 
-	signature, err := rsa.SignPSS(rand.Reader, privateKey, hash, hashedData, nil)
+	if _, ok := privateKey.Public().(*rsa.PublicKey); !ok {
+		return errors.New("unsupported private key type, required RSA")
+	}
+
+	signature, err := privateKey.Sign(rand.Reader, hashedData, &rsa.PSSOptions{
+		SaltLength: rsa.PSSSaltLengthEqualsHash,
+		Hash:       hash,
+	})
 	if err != nil {
 		return err
 	}
@@ -199,32 +292,83 @@ func (c *Container) Sign(privateKey *rsa.PrivateKey, algorithm string) error {
 	return nil
 }
 
+// IsValidFor determines whether a container's signature and certification chain are
+// valid, and that the chain is bound to the given product. Applications which share a
+// root certificate between several products should use this to reject licenses signed
+// by another product's certificates.
+func (c *Container) IsValidFor(rootCertificate *x509.Certificate, productID string) (bool, error) {
+	binding, err := c.verify(rootCertificate)
+	if err != nil {
+		return false, err
+	}
+
+	if binding == nil || binding.ProductID != productID {
+		actual := ""
+		if binding != nil {
+			actual = binding.ProductID
+		}
+
+		return false, &WrongProductError{Expected: productID, Actual: actual}
+	}
+
+	return true, nil
+}
+
 // IsValid is responsible for determining whether a container is valid by validating
-// the signature of its data and the certification chain of that signature.
+// the signature of its data and the certification chain of that signature. Certificates
+// carrying a license binding must agree with those which issued them on the product
+// they are bound to, and if the root certificate is bound to a product then the license
+// must have been signed by a certificate for that product.
 func (c *Container) IsValid(rootCertificate *x509.Certificate) (bool, error) {
+	binding, err := c.verify(rootCertificate)
+	if err != nil {
+		return false, err
+	}
+
+	rootBinding, err := ParseLicenseBinding(rootCertificate)
+	if err != nil {
+		return false, err
+	}
+
+	if rootBinding != nil && (binding == nil || binding.ProductID != rootBinding.ProductID) {
+		return c.IsValidFor(rootCertificate, rootBinding.ProductID)
+	}
+
+	return true, nil
+}
+
+// verify validates the container's signature and certification chain, returning the
+// license binding of the most specific bound certificate in the chain, if any.
+func (c *Container) verify(rootCertificate *x509.Certificate) (*LicenseBinding, error) {
 	if len(c.Certificates) == 0 {
-		return false, errors.New("expected at least one certificate to be present")
+		return nil, errors.New("expected at least one certificate to be present")
 	}
 
 	if !c.Certificates[0].Equal(rootCertificate) {
-		return false, errors.New("expected first certificate in list to match known root")
+		return nil, ErrUntrustedRoot
 	}
 
-	for i := 1; i < len(c.Certificates); i++ {
-		err := c.Certificates[i].CheckSignatureFrom(c.Certificates[i-1])
-		if err != nil {
-			return false, err
-		}
+	if err := VerifyChain(c.Certificates, time.Now()); err != nil {
+		return nil, err
+	}
+
+	binding, err := chainBinding(c.Certificates)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Signature == nil {
+		return nil, errors.New("expected the license to be signed")
 	}
 
 	hash, err := hashByName(c.Signature.Algorithm)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
-	hashedData, err := computeHash(c.Payload.Data, hash)
+	hashedData, err := computeHash(c.Payload.signedData(), hash)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	parentCert := c.Certificates[len(c.Certificates)-1]
@@ -232,14 +376,14 @@ func (c *Container) IsValid(rootCertificate *x509.Certificate) (bool, error) {
 	case x509.RSA:
 		err := rsa.VerifyPSS(parentCert.PublicKey.(*rsa.PublicKey), hash, hashedData, c.Signature.Data, nil)
 		if err != nil {
-			return false, errors.New("signature did not match the expected signed value")
+			return nil, ErrInvalidSignature
 		}
 
 	default:
-		return false, errors.New("unsupported public key algorithm for certificate, required RSA")
+		return nil, errors.New("unsupported public key algorithm for certificate, required RSA")
 	}
 
-	return true, nil
+	return binding, nil
 }
 
 func computeHash(data []byte, algorithm crypto.Hash) ([]byte, error) {
@@ -15,7 +15,6 @@ import (
 var demoLicenseData = []byte(demoData)
 
 func TestSignContainer(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -26,6 +25,7 @@ func TestSignContainer(t *testing.T) {
 
 	km := NewKeyManager(machineCode)
 	km.Path = testPath
+	km.KeySize = 1024
 
 	key, err := km.GetPrivateKey()
 	if err != nil {
@@ -76,7 +76,6 @@ func TestSignContainer(t *testing.T) {
 }
 
 func TestEncodeContainer(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -87,6 +86,7 @@ func TestEncodeContainer(t *testing.T) {
 
 	km := NewKeyManager(machineCode)
 	km.Path = testPath
+	km.KeySize = 1024
 
 	rootKey, err := km.GetPrivateKey()
 	if err != nil {
@@ -140,13 +140,16 @@ func TestEncodeContainer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	cert := cm.Prepare(csr, &Data{
+	cert, err := cm.Prepare(csr, &Data{
 		Meta: &Metadata{
 			ID:          "test",
 			ActivatesOn: testTime,
 			ExpiresOn:   testTime,
 		},
 	})
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	signedCert, err := cm.Sign(cert, rootKey)
 	if err != nil {
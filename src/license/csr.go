@@ -0,0 +1,70 @@
+package license
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+)
+
+// CertificateRequestType is used to armour certificate signing requests
+// produced by a KeyManager.
+const CertificateRequestType = "LITHIUM CERTIFICATE REQUEST"
+
+// pemCertificateRequestType is the standard PEM type used for certificate
+// signing requests by tools like OpenSSL.
+const pemCertificateRequestType = "CERTIFICATE REQUEST"
+
+// CreateCertificateRequest produces a PEM encoded certificate signing request
+// for this machine's public key. The request is signed using the matching
+// private key, proving possession of that key to the issuer.
+func (m *KeyManager) CreateCertificateRequest(subject pkix.Name) ([]byte, error) {
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	template := x509.CertificateRequest{
+		Subject:            subject,
+		SignatureAlgorithm: x509.SHA256WithRSA,
+	}
+
+	csrData, err := x509.CreateCertificateRequest(rand.Reader, &template, privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  CertificateRequestType,
+		Bytes: csrData,
+	}), nil
+}
+
+// ParseCertificateRequest parses a PEM or DER encoded certificate signing
+// request and verifies that it was signed by the private key matching the
+// public key it contains.
+func ParseCertificateRequest(data []byte) (*x509.CertificateRequest, error) {
+	der := data
+
+	block, _ := pem.Decode(data)
+	if block != nil {
+		if block.Type != CertificateRequestType && block.Type != pemCertificateRequestType {
+			return nil, errors.New("certificate request was not of the correct type")
+		}
+
+		der = block.Bytes
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, err
+	}
+
+	err = csr.CheckSignature()
+	if err != nil {
+		return nil, err
+	}
+
+	return csr, nil
+}
@@ -0,0 +1,62 @@
+package license
+
+import (
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCreateCertificateRequest(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+
+	csrData, err := km.CreateCertificateRequest(pkix.Name{CommonName: "machine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(csrData)
+	if block == nil || block.Type != CertificateRequestType {
+		t.Fatalf("expected request to be a PEM block of type '%s'", CertificateRequestType)
+	}
+
+	csr, err := ParseCertificateRequest(csrData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if csr.Subject.CommonName != "machine" {
+		t.Errorf("expected CN='machine', got '%s'", csr.Subject.CommonName)
+	}
+
+	pub, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !pub.Equal(csr.PublicKey) {
+		t.Error("expected the request to contain the machine's public key")
+	}
+
+	_, err = ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Error("expected DER encoded requests to be accepted: ", err)
+	}
+
+	block.Bytes[len(block.Bytes)-1] ^= 0xff
+	_, err = ParseCertificateRequest(pem.EncodeToMemory(block))
+	if err == nil {
+		t.Error("expected a request with an invalid signature to be rejected")
+	}
+}
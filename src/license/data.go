@@ -1,6 +1,8 @@
 package license
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"time"
@@ -21,6 +23,21 @@ type Metadata struct {
 	ActivatesOn time.Time            `json:"activates"`
 	ExpiresOn   time.Time            `json:"expires"`
 	Pack        map[string]*Template `json:"pack,omitempty"`
+
+	// Product is the ID of the product the license was issued for.
+	Product string `json:"product,omitempty"`
+
+	// Template is the name of the pack template from which the license
+	// was issued, if any.
+	Template string `json:"template,omitempty"`
+
+	// Parent is the ID of the license from whose pack the license was
+	// issued, if any.
+	Parent string `json:"parent,omitempty"`
+
+	// LicenseTemplate is the name of the license template from which the
+	// license was issued, if any.
+	LicenseTemplate string `json:"licenseTemplate,omitempty"`
 }
 
 // Template represents a class of licenses as well as the number of licenses
@@ -53,3 +70,14 @@ func DecodeData(data []byte) (*Data, error) {
 
 	return &d, nil
 }
+
+// NewID generates a random identifier suitable for use as a license ID.
+func NewID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
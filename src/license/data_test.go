@@ -138,3 +138,23 @@ func TestDecodeData(t *testing.T) {
 	}
 
 }
+
+func TestNewID(t *testing.T) {
+	id1, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id2, err := NewID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(id1) != 32 {
+		t.Errorf("expected a 32 character ID, got '%s'", id1)
+	}
+
+	if id1 == id2 {
+		t.Error("expected IDs to be unique")
+	}
+}
@@ -0,0 +1,271 @@
+package license
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// LicenseBindingOID identifies the X.509 extension used to bind a
+// certificate to the product, and license, for which it was issued. The
+// extension's value is the DER encoding of the following structure.
+//
+//	LicenseBinding ::= SEQUENCE {
+//	    productId     UTF8String,
+//	    licenseId     [0] EXPLICIT UTF8String OPTIONAL,
+//	    template      [1] EXPLICIT UTF8String OPTIONAL,
+//	    payloadDigest [2] EXPLICIT OCTET STRING OPTIONAL -- SHA-256
+//	    pack          [3] EXPLICIT SEQUENCE OF PackBinding OPTIONAL
+//	}
+//
+//	PackBinding ::= SEQUENCE {
+//	    template      UTF8String,
+//	    digest        OCTET STRING -- SHA-256
+//	}
+var LicenseBindingOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 49270, 1, 1}
+
+// LicenseBinding describes the product and license to which a certificate
+// is bound by its LicenseBindingOID extension.
+type LicenseBinding struct {
+	ProductID string `asn1:"utf8"`
+
+	// LicenseID is the ID of the license the certificate was issued for,
+	// it is empty for intermediate certificates issued to license servers.
+	LicenseID string `asn1:"optional,explicit,tag:0,utf8"`
+
+	// Template is the name of the pack template from which the license the
+	// certificate was issued for was itself issued, if any.
+	Template string `asn1:"optional,explicit,tag:1,utf8"`
+
+	// PayloadDigest is the SHA-256 digest of the payload permitted by the
+	// license the certificate was issued for.
+	PayloadDigest []byte `asn1:"optional,explicit,tag:2"`
+
+	// Pack lists the templates from which licenses may be issued by the
+	// holder of the license the certificate was issued for.
+	Pack []PackBinding `asn1:"optional,explicit,tag:3"`
+}
+
+// PackBinding describes a template of a license's pack, permitting the
+// license's holder to issue licenses whose payload and nested pack match
+// the template's digest.
+type PackBinding struct {
+	Template string `asn1:"utf8"`
+
+	// Digest is the SHA-256 digest of the template's payload and pack, as
+	// computed by TemplateDigest.
+	Digest []byte
+}
+
+// NewPackBindings describes each of the templates within a license's
+// pack, ordered by their names.
+func NewPackBindings(pack map[string]*Template) ([]PackBinding, error) {
+	if len(pack) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(pack))
+	for name := range pack {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bindings := make([]PackBinding, 0, len(names))
+	for _, name := range names {
+		tmpl := pack[name]
+		if tmpl == nil {
+			tmpl = &Template{}
+		}
+
+		digest, err := TemplateDigest(tmpl.Payload, tmpl.Pack)
+		if err != nil {
+			return nil, err
+		}
+
+		bindings = append(bindings, PackBinding{Template: name, Digest: digest})
+	}
+
+	return bindings, nil
+}
+
+// Extension encodes the binding as a non-critical X.509 extension.
+func (b *LicenseBinding) Extension() (pkix.Extension, error) {
+	if b.ProductID == "" {
+		return pkix.Extension{}, errors.New("expected the license binding to specify a product")
+	}
+
+	value, err := asn1.Marshal(*b)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+
+	return pkix.Extension{
+		Id:    LicenseBindingOID,
+		Value: value,
+	}, nil
+}
+
+// Permits determines whether the given license data is consistent with
+// this binding. Licenses must name the bound product, and certificates
+// which were issued for a license may only sign that license, whose
+// payload must match the bound digest, or the licenses issued from one of
+// the templates of its pack, whose payload and nested pack must match the
+// template's. The number of licenses issued from each template is tracked
+// by the issuer's PackCounter and cannot be enforced here.
+func (b *LicenseBinding) Permits(data *Data) error {
+	if data == nil || data.Meta == nil {
+		return errors.New("license metadata not defined")
+	}
+
+	if data.Meta.Product != b.ProductID {
+		return &WrongProductError{Expected: b.ProductID, Actual: data.Meta.Product}
+	}
+
+	if b.LicenseID == "" {
+		return nil
+	}
+
+	if data.Meta.Parent == b.LicenseID {
+		return b.permitsSublicense(data)
+	}
+
+	if data.Meta.ID != b.LicenseID {
+		return fmt.Errorf("license '%s' was signed by a certificate issued for license '%s'", data.Meta.ID, b.LicenseID)
+	}
+
+	if b.PayloadDigest != nil {
+		digest, err := PayloadDigest(data.Payload)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(digest, b.PayloadDigest) {
+			return errors.New("license payload does not match the payload permitted by its certificate")
+		}
+	}
+
+	return nil
+}
+
+func (b *LicenseBinding) permitsSublicense(data *Data) error {
+	for _, pack := range b.Pack {
+		if pack.Template != data.Meta.Template {
+			continue
+		}
+
+		digest, err := TemplateDigest(data.Payload, data.Meta.Pack)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(digest, pack.Digest) {
+			return fmt.Errorf("license does not match the '%s' template permitted by its certificate", pack.Template)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("license '%s' was not issued from a template in the pack of license '%s'", data.Meta.ID, b.LicenseID)
+}
+
+// WrongProductError is returned when a license, or the certificate which
+// signed it, was issued for a product other than the one expected.
+type WrongProductError struct {
+	Expected string
+	Actual   string
+}
+
+func (e *WrongProductError) Error() string {
+	if e.Actual == "" {
+		return fmt.Sprintf("license was not issued for product '%s'", e.Expected)
+	}
+
+	return fmt.Sprintf("license was issued for product '%s', not '%s'", e.Actual, e.Expected)
+}
+
+// ParseLicenseBinding extracts the license binding from a certificate,
+// returning nil if the certificate does not carry one.
+func ParseLicenseBinding(cert *x509.Certificate) (*LicenseBinding, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(LicenseBindingOID) {
+			continue
+		}
+
+		var b LicenseBinding
+		rest, err := asn1.Unmarshal(ext.Value, &b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid license binding extension: %s", err)
+		}
+
+		if len(rest) > 0 {
+			return nil, errors.New("invalid license binding extension: trailing data")
+		}
+
+		return &b, nil
+	}
+
+	return nil, nil
+}
+
+// PayloadDigest computes the SHA-256 digest of a license payload's JSON
+// encoding, as recorded within license bindings and the issuance ledger.
+func PayloadDigest(payload map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// TemplateDigest computes the SHA-256 digest of the JSON encoding of a
+// pack template's payload and nested pack, as recorded within the license
+// bindings of the certificates issued for the licenses carrying the pack.
+func TemplateDigest(payload map[string]interface{}, pack map[string]*Template) ([]byte, error) {
+	data, err := json.Marshal(struct {
+		Payload map[string]interface{} `json:"payload,omitempty"`
+		Pack    map[string]*Template   `json:"pack,omitempty"`
+	}{payload, pack})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// chainBinding verifies that the license bindings of every certificate in
+// a chain agree on the product they were issued for, returning the binding
+// of the most specific bound certificate, or nil if none are bound. Root
+// certificates may be shared by several products, so the certificates they
+// issue need not agree with the root's own binding.
+func chainBinding(certs []*x509.Certificate) (*LicenseBinding, error) {
+	var binding *LicenseBinding
+	fromRoot := false
+	for i, cert := range certs {
+		b, err := ParseLicenseBinding(cert)
+		if err != nil {
+			return nil, err
+		}
+
+		if b == nil {
+			continue
+		}
+
+		if binding != nil && !fromRoot && binding.ProductID != b.ProductID {
+			return nil, fmt.Errorf("certificate for product '%s' was issued by a certificate for product '%s'", b.ProductID, binding.ProductID)
+		}
+
+		binding = b
+		fromRoot = i == 0
+	}
+
+	return binding, nil
+}
@@ -0,0 +1,383 @@
+package license
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLicenseBindingExtension(t *testing.T) {
+	digest, err := PayloadDigest(map[string]interface{}{"seats": 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pack, err := NewPackBindings(map[string]*Template{"standard": {Count: 2, Payload: map[string]interface{}{"seats": 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &LicenseBinding{
+		ProductID:     "testing",
+		LicenseID:     "license1",
+		Template:      "standard",
+		PayloadDigest: digest,
+		Pack:          pack,
+	}
+
+	ext, err := b.Extension()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := ParseLicenseBinding(&x509.Certificate{Extensions: []pkix.Extension{ext}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed == nil {
+		t.Fatal("expected the license binding to be found")
+	}
+
+	if parsed.ProductID != b.ProductID || parsed.LicenseID != b.LicenseID || parsed.Template != b.Template || !bytes.Equal(parsed.PayloadDigest, digest) {
+		t.Errorf("expected the parsed binding to match, got %+v", parsed)
+	}
+
+	if len(parsed.Pack) != 1 || parsed.Pack[0].Template != "standard" || !bytes.Equal(parsed.Pack[0].Digest, pack[0].Digest) {
+		t.Errorf("expected the parsed binding's pack to match, got %+v", parsed.Pack)
+	}
+
+	err = parsed.Permits(&Data{
+		Meta:    &Metadata{ID: "license1", Product: "testing"},
+		Payload: map[string]interface{}{"seats": 10},
+	})
+	if err == nil {
+		t.Error("expected a modified payload not to be permitted")
+	}
+
+	err = parsed.Permits(&Data{
+		Meta:    &Metadata{ID: "license1", Product: "testing"},
+		Payload: map[string]interface{}{"seats": 5},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, err := (&LicenseBinding{}).Extension(); err == nil {
+		t.Error("expected a binding without a product to fail")
+	}
+}
+
+func TestContainerProductBinding(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+	cmA := NewCertManager(&Product{ID: "a", Name: "Product A", Organization: "Sierra Softworks"})
+	cmB := NewCertManager(&Product{ID: "b", Name: "Product B", Organization: "Sierra Softworks"})
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "a"
+	keyA, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "b"
+	keyB, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certA, err := cmA.CreateIntermediate(rootCert, rootKey, keyA.Public(), &IntermediateOptions{
+		Name:       "server",
+		MaxPathLen: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := Container{}
+	err = c.SetLicense(&Data{
+		Meta: &Metadata{
+			ID:          "license1",
+			Product:     "b",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{},
+	}, &rootKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Certificates = []*x509.Certificate{rootCert}
+
+	err = c.Sign(rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isValid, err := c.IsValidFor(rootCert, testProduct.ID)
+	if !isValid {
+		t.Fatal("expected a license signed by the root certificate to be valid for its product: ", err)
+	}
+
+	err = c.Sign(keyA, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Certificates = []*x509.Certificate{rootCert, certA}
+
+	isValid, err = c.IsValidFor(rootCert, "a")
+	if !isValid {
+		t.Fatal(err)
+	}
+
+	isValid, err = c.IsValidFor(rootCert, "b")
+	if isValid || err == nil {
+		t.Error("expected product A's certificate not to validate licenses for product B")
+	}
+
+	_, err = c.License(rootKey, rootCert)
+	if err == nil {
+		t.Error("expected a license for product B signed by product A's certificate to be rejected")
+	}
+
+	certB, err := cmB.CreateIntermediate(certA, keyA, keyB.Public(), &IntermediateOptions{
+		Name: "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Sign(keyB, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.Certificates = []*x509.Certificate{rootCert, certA, certB}
+
+	isValid, err = c.IsValid(rootCert)
+	if isValid || err == nil {
+		t.Error("expected a certificate for product B issued by product A's certificate to be rejected")
+	}
+}
+
+func TestContainerSharedRoot(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+	cmA := NewCertManager(&Product{ID: "a", Name: "Product A", Organization: "Sierra Softworks"})
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "a"
+	keyA, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certA, err := cmA.CreateIntermediate(rootCert, rootKey, keyA.Public(), &IntermediateOptions{Name: "server"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, product := range []string{"", "a"} {
+		c := Container{Certificates: []*x509.Certificate{rootCert, certA}}
+		err = c.SetLicense(&Data{
+			Meta: &Metadata{
+				ID:          "license1",
+				Product:     product,
+				ActivatesOn: time.Now(),
+				ExpiresOn:   time.Now().Add(time.Hour),
+			},
+			Payload: map[string]interface{}{},
+		}, &rootKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Sign(keyA, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := c.LicenseFor(rootKey, rootCert, "b"); err == nil {
+			t.Errorf("expected a license naming product '%s' signed by product A's certificate not to be valid for product B", product)
+		}
+
+		if _, err := c.IsValid(rootCert); err == nil {
+			t.Errorf("expected a license naming product '%s' signed by product A's certificate not to be valid for the root's product", product)
+		} else if _, ok := err.(*WrongProductError); !ok {
+			t.Errorf("expected a WrongProductError, got %v", err)
+		}
+
+		if _, err := c.License(rootKey, rootCert); err == nil {
+			t.Errorf("expected a license naming product '%s' not to be decrypted for the root's product", product)
+		}
+
+		_, err = c.LicenseFor(rootKey, rootCert, "a")
+		if product == "a" && err != nil {
+			t.Errorf("expected a license for product A to be valid for product A, got %v", err)
+		} else if product == "" && err == nil {
+			t.Error("expected a license which does not name its product to be rejected")
+		}
+	}
+}
+
+func TestContainerLicenseBinding(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "holder"
+	holderKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrData, err := km.CreateCertificateRequest(pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := ParseCertificateRequest(csrData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := &Data{
+		Meta: &Metadata{
+			ID:          "parent",
+			Product:     testProduct.ID,
+			ActivatesOn: time.Now().Add(-time.Minute),
+			ExpiresOn:   time.Now().Add(time.Hour),
+			Pack:        map[string]*Template{"standard": {Count: 1}},
+		},
+		Payload: map[string]interface{}{"seats": 5.0},
+	}
+
+	template, err := cm.Prepare(csr, parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	holderCert, err := cm.Sign(template, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(meta Metadata, payload map[string]interface{}) *Container {
+		meta.Product = testProduct.ID
+		meta.ActivatesOn = time.Now().Add(-time.Minute)
+		meta.ExpiresOn = time.Now().Add(time.Hour)
+
+		c := &Container{Certificates: []*x509.Certificate{rootCert, holderCert}}
+		err := c.SetLicense(&Data{Meta: &meta, Payload: payload}, &rootKey.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Sign(holderKey, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	if _, err := signed(Metadata{ID: "child", Parent: "parent", Template: "standard"}, map[string]interface{}{}).License(rootKey, rootCert); err != nil {
+		t.Errorf("expected a license issued from the holder's pack to be valid, got %v", err)
+	}
+
+	if _, err := signed(Metadata{ID: "child", Parent: "parent", Template: "premium"}, map[string]interface{}{}).License(rootKey, rootCert); err == nil {
+		t.Error("expected a license issued from a template outside the holder's pack to be rejected")
+	}
+
+	if _, err := signed(Metadata{ID: "child", Parent: "parent", Template: "standard"}, map[string]interface{}{"seats": 500.0}).License(rootKey, rootCert); err == nil {
+		t.Error("expected a license with a payload other than its template's to be rejected")
+	}
+
+	if _, err := signed(Metadata{ID: "child", Parent: "parent", Template: "standard", Pack: map[string]*Template{"standard": {Count: 100}}}, map[string]interface{}{}).License(rootKey, rootCert); err == nil {
+		t.Error("expected a license with a pack other than its template's to be rejected")
+	}
+
+	if _, err := signed(Metadata{ID: "parent"}, map[string]interface{}{"seats": 5.0}).License(rootKey, rootCert); err != nil {
+		t.Errorf("expected the holder's own license to be valid, got %v", err)
+	}
+
+	if _, err := signed(Metadata{ID: "parent"}, map[string]interface{}{"seats": 500.0}).License(rootKey, rootCert); err == nil {
+		t.Error("expected the holder's license with a modified payload to be rejected")
+	}
+
+	if _, err := signed(Metadata{ID: "renamed"}, map[string]interface{}{"seats": 500.0}).License(rootKey, rootCert); err == nil {
+		t.Error("expected a license which was not issued from the holder's pack to be rejected")
+	}
+}
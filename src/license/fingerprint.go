@@ -0,0 +1,39 @@
+package license
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"strings"
+)
+
+// KeyFingerprint returns the hex encoded SHA-256 hash of the DER encoded
+// form of a public key. It is used to identify keys within ledgers and
+// license requests.
+func KeyFingerprint(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// ShortFingerprint returns an abbreviated form of a public key's
+// fingerprint, grouped for easy comparison by a person, for example
+// "3F2A-9C41-07BE-D5E8".
+func ShortFingerprint(pub crypto.PublicKey) (string, error) {
+	fingerprint, err := KeyFingerprint(pub)
+	if err != nil {
+		return "", err
+	}
+
+	groups := []string{}
+	for i := 0; i < 16; i += 4 {
+		groups = append(groups, strings.ToUpper(fingerprint[i:i+4]))
+	}
+
+	return strings.Join(groups, "-"), nil
+}
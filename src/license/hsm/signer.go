@@ -0,0 +1,87 @@
+package hsm
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/miekg/pkcs11"
+)
+
+// digestInfoPrefixes are the DER encoded DigestInfo prefixes which must be
+// prepended to a hash before it is signed using the raw CKM_RSA_PKCS mechanism.
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// pssMechanisms maps hash functions onto their PKCS#11 hash and MGF
+// identifiers for use with the CKM_RSA_PKCS_PSS mechanism.
+var pssMechanisms = map[crypto.Hash][2]uint{
+	crypto.SHA1:   {pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1},
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// signer is a crypto.Signer backed by an RSA private key held on a
+// PKCS#11 token.
+type signer struct {
+	token  *Token
+	handle pkcs11.ObjectHandle
+	pub    *rsa.PublicKey
+}
+
+func (s *signer) Public() crypto.PublicKey {
+	return s.pub
+}
+
+func (s *signer) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+	if len(digest) != hash.Size() {
+		return nil, errors.New("digest length does not match the hash function")
+	}
+
+	var mechanism *pkcs11.Mechanism
+	var data []byte
+
+	if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+		ids, ok := pssMechanisms[hash]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function for PSS signatures: %v", hash)
+		}
+
+		saltLength := pssOpts.SaltLength
+		switch saltLength {
+		case rsa.PSSSaltLengthEqualsHash:
+			saltLength = hash.Size()
+		case rsa.PSSSaltLengthAuto:
+			saltLength = (s.pub.N.BitLen()-1+7)/8 - 2 - hash.Size()
+		}
+
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, pkcs11.NewPSSParams(ids[0], ids[1], uint(saltLength)))
+		data = digest
+	} else {
+		prefix, ok := digestInfoPrefixes[hash]
+		if !ok {
+			return nil, fmt.Errorf("unsupported hash function for PKCS#1 signatures: %v", hash)
+		}
+
+		mechanism = pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)
+		data = append(append([]byte{}, prefix...), digest...)
+	}
+
+	s.token.lock.Lock()
+	defer s.token.lock.Unlock()
+
+	err := s.token.ctx.SignInit(s.token.session, []*pkcs11.Mechanism{mechanism}, s.handle)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.token.ctx.Sign(s.token.session, data)
+}
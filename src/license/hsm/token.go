@@ -0,0 +1,272 @@
+// Package hsm provides access to signing keys held within PKCS#11
+// hardware tokens, exposing them as crypto.Signer implementations
+// which may be used with the license package's CertManager and
+// Container types.
+package hsm
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/miekg/pkcs11"
+)
+
+// Config describes the PKCS#11 module and token which should be used
+// to access signing keys.
+type Config struct {
+	// Module is the path to the PKCS#11 module (shared library) which
+	// provides access to the token, for example libsofthsm2.so.
+	Module string
+
+	// TokenLabel is the label of the token on which keys are stored.
+	TokenLabel string
+
+	// PIN is the user PIN used to log into the token.
+	PIN string
+}
+
+// Token represents an open, authenticated session with a PKCS#11 token.
+type Token struct {
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	lock    sync.Mutex
+}
+
+// Open will load the configured PKCS#11 module, locate the token with
+// the configured label and log into it using the configured PIN.
+func Open(cfg *Config) (*Token, error) {
+	if cfg == nil || cfg.Module == "" {
+		return nil, errors.New("expected a PKCS#11 module to be provided")
+	}
+
+	ctx := pkcs11.New(cfg.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not load PKCS#11 module '%s'", cfg.Module)
+	}
+
+	err := ctx.Initialize()
+	if err != nil {
+		ctx.Destroy()
+		return nil, err
+	}
+
+	slot, err := findSlot(ctx, cfg.TokenLabel)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+
+	session, err := ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION)
+	if err != nil {
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+
+	err = ctx.Login(session, pkcs11.CKU_USER, cfg.PIN)
+	if err != nil && err != pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN) {
+		ctx.CloseSession(session)
+		ctx.Finalize()
+		ctx.Destroy()
+		return nil, err
+	}
+
+	return &Token{
+		ctx:     ctx,
+		session: session,
+	}, nil
+}
+
+// Close will log out of the token and unload the PKCS#11 module. Any
+// signers retrieved from the token will no longer be usable.
+func (t *Token) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.ctx.Logout(t.session)
+	t.ctx.CloseSession(t.session)
+	err := t.ctx.Finalize()
+	t.ctx.Destroy()
+	return err
+}
+
+// Signer retrieves a crypto.Signer for the RSA keypair with the given
+// label on this token.
+func (t *Token) Signer(label string) (crypto.Signer, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	priv, err := t.findObject(pkcs11.CKO_PRIVATE_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+
+	pubHandle, err := t.findObject(pkcs11.CKO_PUBLIC_KEY, label)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := t.readPublicKey(pubHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signer{
+		token:  t,
+		handle: priv,
+		pub:    pub,
+	}, nil
+}
+
+// GenerateRSAKey will generate a new RSA keypair on the token, with the
+// given label, and return a crypto.Signer for it. The private key is
+// marked as sensitive and non-extractable, ensuring that it never leaves
+// the token.
+func (t *Token) GenerateRSAKey(label string, bits int) (crypto.Signer, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if _, err := t.findObject(pkcs11.CKO_PRIVATE_KEY, label); err == nil {
+		return nil, fmt.Errorf("a key with the label '%s' already exists on the token", label)
+	}
+
+	publicTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, []byte{1, 0, 1}),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS_BITS, bits),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	privateTemplate := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	pubHandle, privHandle, err := t.ctx.GenerateKeyPair(
+		t.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
+		publicTemplate,
+		privateTemplate,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := t.readPublicKey(pubHandle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signer{
+		token:  t,
+		handle: privHandle,
+		pub:    pub,
+	}, nil
+}
+
+func (t *Token) findObject(class uint, label string) (pkcs11.ObjectHandle, error) {
+	err := t.ctx.FindObjectsInit(t.session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	objects, _, err := t.ctx.FindObjects(t.session, 1)
+	finalErr := t.ctx.FindObjectsFinal(t.session)
+	if err != nil {
+		return 0, err
+	}
+
+	if finalErr != nil {
+		return 0, finalErr
+	}
+
+	if len(objects) == 0 {
+		return 0, fmt.Errorf("no key with the label '%s' was found on the token", label)
+	}
+
+	return objects[0], nil
+}
+
+func (t *Token) readPublicKey(handle pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
+	attrs, err := t.ctx.GetAttributeValue(t.session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pub := rsa.PublicKey{}
+	for _, attr := range attrs {
+		switch attr.Type {
+		case pkcs11.CKA_MODULUS:
+			pub.N = new(big.Int).SetBytes(attr.Value)
+		case pkcs11.CKA_PUBLIC_EXPONENT:
+			pub.E = int(new(big.Int).SetBytes(attr.Value).Int64())
+		}
+	}
+
+	if pub.N == nil || pub.E == 0 {
+		return nil, errors.New("token did not provide a complete RSA public key")
+	}
+
+	return &pub, nil
+}
+
+func findSlot(ctx *pkcs11.Ctx, label string) (uint, error) {
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, slot := range slots {
+		info, err := ctx.GetTokenInfo(slot)
+		if err != nil {
+			continue
+		}
+
+		if label == "" || info.Label == label {
+			return slot, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no PKCS#11 token with the label '%s' was found", label)
+}
+
+// KeyRefPrefix identifies certificate chain key references which refer to
+// a key held within a PKCS#11 token rather than a key file.
+const KeyRefPrefix = "pkcs11:"
+
+// KeyRef returns the certificate chain key reference for the token key with
+// the given label.
+func KeyRef(label string) string {
+	return KeyRefPrefix + label
+}
+
+// ParseKeyRef extracts the key label from a certificate chain key reference,
+// returning false if the reference does not refer to a token key.
+func ParseKeyRef(ref string) (string, bool) {
+	if !strings.HasPrefix(ref, KeyRefPrefix) {
+		return "", false
+	}
+
+	return strings.TrimPrefix(ref, KeyRefPrefix), true
+}
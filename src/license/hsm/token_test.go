@@ -0,0 +1,102 @@
+package hsm
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// openTestToken opens the token described by the LITHIUM_PKCS11_MODULE,
+// LITHIUM_PKCS11_TOKEN and LITHIUM_PKCS11_PIN environment variables, skipping
+// the test if no module has been configured. SoftHSM may be used for this
+// purpose by initializing a token with softhsm2-util.
+func openTestToken(t *testing.T) *Token {
+	module := os.Getenv("LITHIUM_PKCS11_MODULE")
+	if module == "" {
+		t.Skip("LITHIUM_PKCS11_MODULE not set, skipping PKCS#11 tests")
+	}
+
+	token, err := Open(&Config{
+		Module:     module,
+		TokenLabel: os.Getenv("LITHIUM_PKCS11_TOKEN"),
+		PIN:        os.Getenv("LITHIUM_PKCS11_PIN"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func TestOpenWithoutModule(t *testing.T) {
+	_, err := Open(&Config{})
+	if err == nil {
+		t.Error("expected an error when no module was provided")
+	}
+}
+
+func TestTokenSignsContainer(t *testing.T) {
+	token := openTestToken(t)
+	defer token.Close()
+
+	label := fmt.Sprintf("lithium-test-%d", time.Now().UnixNano())
+	key, err := token.GenerateRSAKey(label, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		t.Fatal("expected the token to provide an RSA public key")
+	}
+
+	cm := license.NewCertManager(&license.Product{
+		ID:           "testing",
+		Name:         "Lithium Testing",
+		Organization: "Sierra Softworks",
+	})
+
+	cert, err := cm.CreateRoot(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := license.Container{
+		Certificates: []*x509.Certificate{cert},
+	}
+
+	err = c.SetLicense(&license.Data{
+		Meta: &license.Metadata{
+			ID:          "0",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now(),
+		},
+		Payload: map[string]interface{}{},
+	}, key.Public().(*rsa.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.Sign(key, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isValid, err := c.IsValid(cert)
+	if !isValid {
+		t.Error("expected container signed by the token to be valid: ", err)
+	}
+
+	signer, err := token.Signer(label)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signer.Public().(*rsa.PublicKey).N.Cmp(key.Public().(*rsa.PublicKey).N) != 0 {
+		t.Error("expected to retrieve the same key from the token")
+	}
+}
@@ -0,0 +1,97 @@
+package license
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// InstalledLicenseExtension is the file extension used for license
+// containers which have been installed for a KeyManager's keypair.
+const InstalledLicenseExtension = ".lic"
+
+// InstallLicense will store a license container alongside this machine's
+// keypair, allowing it to be retrieved later. The container must be
+// decryptable using this machine's private key. The decrypted license
+// data is returned so that the caller may validate it.
+func (m *KeyManager) InstallLicense(containerData []byte) (*Data, error) {
+	container, err := ParseContainer(containerData)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := m.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	var data Data
+	err = container.Payload.Decrypt(&data, privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Meta == nil || data.Meta.ID == "" {
+		return nil, fmt.Errorf("license does not have an ID and cannot be installed")
+	}
+
+	err = m.writeLicenseFile(data.Meta.ID, containerData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// InstalledLicenses retrieves the raw containers for every license which
+// has been installed for this machine's keypair, keyed by license ID.
+func (m *KeyManager) InstalledLicenses() (map[string][]byte, error) {
+	err := m.validateNamespace()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(m.LicensesPath(), "*"+InstalledLicenseExtension))
+	if err != nil {
+		return nil, err
+	}
+
+	licenses := map[string][]byte{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(file), InstalledLicenseExtension)
+		licenses[id] = data
+	}
+
+	return licenses, nil
+}
+
+// LicensesPath returns the directory in which licenses installed for this
+// KeyManager's keypair are stored.
+func (m *KeyManager) LicensesPath() string {
+	return filepath.Join(m.namespacePath(), "licenses", m.Profile)
+}
+
+func (m *KeyManager) writeLicenseFile(id string, data []byte) error {
+	if id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return fmt.Errorf("license ID '%s' cannot be used as a file name", id)
+	}
+
+	err := m.validateNamespace()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.LicensesPath(), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(m.LicensesPath(), id+InstalledLicenseExtension), data, 0600)
+}
@@ -0,0 +1,140 @@
+package license
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ProductURIScheme is the URI scheme used to identify products and their
+// license servers within certificates. Intermediate certificates are name
+// constrained to URIs of this scheme whose host is the product's ID.
+const ProductURIScheme = "lithium"
+
+// IntermediateOptions describes the constraints placed on an intermediate
+// certificate issued to a license server.
+type IntermediateOptions struct {
+	// Name identifies the license server the certificate is issued to.
+	Name string
+
+	// MaxPathLen is the number of further intermediate certificates which
+	// may be issued beneath this one. It must be lower than the parent's.
+	MaxPathLen int
+
+	// NotBefore and NotAfter define the validity window of the certificate.
+	// They are limited to the parent's validity window, defaulting to the
+	// current time and the parent's expiry respectively.
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// CreateIntermediate will issue a delegated certificate, allowing the holder
+// of the provided public key to act as a license server for this product.
+// The certificate is signed by the parent certificate using the parent's
+// private key, may not outlive its parent and is name constrained to this
+// product's ID.
+func (m *CertManager) CreateIntermediate(parent *x509.Certificate, parentKey crypto.Signer, pub crypto.PublicKey, opts *IntermediateOptions) (*x509.Certificate, error) {
+	if parent == nil {
+		return nil, errors.New("expected a parent certificate to be provided")
+	}
+
+	if opts == nil || opts.Name == "" {
+		return nil, errors.New("expected a name to be provided for the intermediate certificate")
+	}
+
+	if !parent.IsCA || parent.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New("parent certificate is not permitted to sign certificates")
+	}
+
+	if opts.MaxPathLen < 0 {
+		return nil, errors.New("expected a non-negative path length for the intermediate certificate")
+	}
+
+	if parent.MaxPathLenZero || (parent.MaxPathLen > 0 && opts.MaxPathLen >= parent.MaxPathLen) {
+		return nil, fmt.Errorf("path length %d is not permitted by the parent certificate", opts.MaxPathLen)
+	}
+
+	notBefore := opts.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	if notBefore.Before(parent.NotBefore) {
+		notBefore = parent.NotBefore
+	}
+
+	notAfter := opts.NotAfter
+	if notAfter.IsZero() || notAfter.After(parent.NotAfter) {
+		notAfter = parent.NotAfter
+	}
+
+	if !notAfter.After(notBefore) {
+		return nil, errors.New("intermediate certificate validity falls outside that of its parent")
+	}
+
+	issuer, err := KeyFingerprint(parent.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	serialNumber, err := m.reserveSerial(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := (&LicenseBinding{ProductID: m.Product.ID}).Extension()
+	if err != nil {
+		return nil, err
+	}
+
+	template := x509.Certificate{
+		Subject:                     m.Product.subject(opts.Name),
+		SerialNumber:                serialNumber,
+		BasicConstraintsValid:       true,
+		IsCA:                        true,
+		MaxPathLen:                  opts.MaxPathLen,
+		MaxPathLenZero:              opts.MaxPathLen == 0,
+		NotBefore:                   notBefore,
+		NotAfter:                    notAfter,
+		KeyUsage:                    x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		URIs:                        []*url.URL{productURI(m.Product.ID, "servers", opts.Name)},
+		PermittedURIDomains:         []string{m.Product.ID},
+		PermittedDNSDomainsCritical: true,
+		ExtraExtensions:             []pkix.Extension{binding},
+	}
+
+	certData, err := x509.CreateCertificate(rand.Reader, &template, parent, pub, parentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordCertificate(issuer, cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+func productURI(productID string, path ...string) *url.URL {
+	u := &url.URL{
+		Scheme: ProductURIScheme,
+		Host:   productID,
+	}
+
+	for _, p := range path {
+		u.Path = u.Path + "/" + url.PathEscape(p)
+	}
+
+	return u
+}
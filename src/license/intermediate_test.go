@@ -0,0 +1,218 @@
+package license
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCertManCreateIntermediate(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "server"
+	serverKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := cm.CreateIntermediate(rootCert, rootKey, serverKey.Public(), &IntermediateOptions{
+		Name:       "server1",
+		MaxPathLen: 1,
+		NotAfter:   rootCert.NotAfter.Add(24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cert.CheckSignatureFrom(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cert.IsCA {
+		t.Error("expected intermediate certificate to be a CA")
+	}
+
+	if cert.MaxPathLen != 1 {
+		t.Errorf("expected a path length of 1, got %d", cert.MaxPathLen)
+	}
+
+	if cert.NotAfter.After(rootCert.NotAfter) {
+		t.Error("expected intermediate certificate to expire before its parent")
+	}
+
+	if len(cert.PermittedURIDomains) != 1 || cert.PermittedURIDomains[0] != testProduct.ID {
+		t.Errorf("expected certificate to be constrained to '%s', got %v", testProduct.ID, cert.PermittedURIDomains)
+	}
+
+	if cert.Subject.SerialNumber != "server1" {
+		t.Errorf("expected SN='server1', got '%s'", cert.Subject.SerialNumber)
+	}
+
+	leaf, err := cm.CreateIntermediate(cert, serverKey, serverKey.Public(), &IntermediateOptions{
+		Name: "server2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !leaf.MaxPathLenZero {
+		t.Error("expected nested intermediate to have a zero path length")
+	}
+
+	_, err = cm.CreateIntermediate(leaf, serverKey, serverKey.Public(), &IntermediateOptions{
+		Name: "server3",
+	})
+	if err == nil {
+		t.Error("expected an error when exceeding the parent's path length")
+	}
+}
+
+func TestIntermediateConstraintsEnforced(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "server"
+	serverKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(key *rsa.PrivateKey, certs ...*x509.Certificate) *Container {
+		c := &Container{Certificates: append([]*x509.Certificate{rootCert}, certs...)}
+		err := c.SetLicense(&Data{
+			Meta: &Metadata{
+				ID:          "license1",
+				Product:     testProduct.ID,
+				ActivatesOn: time.Now(),
+				ExpiresOn:   time.Now().Add(time.Hour),
+			},
+			Payload: map[string]interface{}{},
+		}, &key.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = c.Sign(key, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return c
+	}
+
+	issue := func(parent *x509.Certificate, parentKey *rsa.PrivateKey, uri string, isCA bool, notAfter time.Time) *x509.Certificate {
+		u, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template := &x509.Certificate{
+			Subject:               testProduct.subject("rogue"),
+			SerialNumber:          big.NewInt(100),
+			BasicConstraintsValid: true,
+			IsCA:                  isCA,
+			NotBefore:             time.Now().Add(-time.Minute),
+			NotAfter:              notAfter,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			URIs:                  []*url.URL{u},
+		}
+
+		data, err := x509.CreateCertificate(rand.Reader, template, parent, serverKey.Public(), parentKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return cert
+	}
+
+	server, err := cm.CreateIntermediate(rootCert, rootKey, serverKey.Public(), &IntermediateOptions{
+		Name:       "server",
+		MaxPathLen: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isValid, err := signed(serverKey, server).IsValid(rootCert); !isValid {
+		t.Fatal("expected a license signed by the intermediate to be valid: ", err)
+	}
+
+	final, err := cm.CreateIntermediate(rootCert, rootKey, serverKey.Public(), &IntermediateOptions{
+		Name: "final",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	rogue := issue(final, serverKey, "lithium://testing/servers/rogue", true, expires)
+	beyondPath := issue(rogue, serverKey, "lithium://testing/servers/beyond", false, expires)
+	if isValid, err := signed(serverKey, final, rogue, beyondPath).IsValid(rootCert); isValid || err == nil {
+		t.Error("expected a certificate issued beyond the intermediate's path length to be rejected")
+	}
+
+	otherProduct := issue(server, serverKey, "lithium://other/servers/rogue", false, expires)
+	if isValid, err := signed(serverKey, server, otherProduct).IsValid(rootCert); isValid || err == nil {
+		t.Error("expected a certificate outside the intermediate's name constraints to be rejected")
+	}
+
+	expired := issue(rootCert, rootKey, "lithium://testing/servers/expired", true, time.Now().Add(-time.Second))
+	if isValid, err := signed(serverKey, expired).IsValid(rootCert); isValid || err == nil {
+		t.Error("expected a license signed by an expired intermediate to be rejected")
+	}
+}
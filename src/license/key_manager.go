@@ -6,41 +6,88 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 // PrivateKeyType is used to armour the machine's private key when
 // PEM encoded and encrypted.
 const PrivateKeyType = "LITHIUM PRIVATE KEY"
 
-// PrivateKeyName is the name of the private key file. It may be
-// changed if you wish to enable multiple side-by-side installations
-// with different keys. This is usually not necessary.
-var PrivateKeyName = "machine"
-
 // PublicKeyType is used to armour the machine's public key when
 // PEM encoded.
 const PublicKeyType = "LITHIUM PUBLIC KEY"
 
-// PublicKeyName is the name of your public key file. It may be
-// changed if you wish to enable multiple side-by-side installations
-// with different keys. This is usually not necessary.
-var PublicKeyName = "machine.pub"
+// DefaultKeyProfile is the name of the key profile used when none
+// has been specified. Profiles allow a single product to maintain
+// multiple independent keypairs, for example a "machine" key used
+// to decrypt licenses and a "server" key used to sign them.
+const DefaultKeyProfile = "machine"
+
+// DefaultKeyProduct is the namespace used for keys which are not
+// bound to a specific product.
+const DefaultKeyProduct = "default"
 
-// KeySize is the size of the RSA key used for license encryption
+// DefaultKeyAlgorithm is the algorithm used to generate keys when
+// none has been specified.
+const DefaultKeyAlgorithm = "rsa"
+
+// DefaultKeySize is the size of the RSA key used for license encryption
 // and decryption. 2048 is probably secure enough for any general
 // use, however you can reduce this to 1024 or increase to 4096
 // to balance generation speed and security.
-var KeySize = 2048
+const DefaultKeySize = 2048
+
+// ErrNoMachineCode is returned when a KeyManager without a machine code is
+// asked to generate or import a private key, which would otherwise be
+// stored without meaningful protection.
+var ErrNoMachineCode = errors.New("a machine code is required to protect this machine's private key")
+
+// legacyPrivateKeyName and legacyPublicKeyName are the names of the
+// key files which were shared by every product prior to the introduction
+// of key namespaces. They are migrated into the default profile of the
+// LegacyProduct's namespace the first time it is used.
+const (
+	legacyPrivateKeyName = "machine"
+	legacyPublicKeyName  = "machine.pub"
+)
 
 // KeyManager provides a set of tools for accessing your machine's
 // local key. This is tied to your machine and allows you to decrypt
 // your license packs.
+//
+// Keys are stored within a namespace determined by the Product and
+// Profile fields, at Path/keys/Product/Profile, allowing side-by-side
+// products to maintain separate keys within a single process.
 type KeyManager struct {
 	MachineCode []byte
 	Path        string
+
+	// Product is the ID of the product whose keys are managed by
+	// this KeyManager.
+	Product string
+
+	// Profile is the name of the keypair within the product's
+	// namespace.
+	Profile string
+
+	// LegacyProduct is the ID of the product which adopts the keypair
+	// previously shared by every product, defaulting to DefaultKeyProduct.
+	// Every other product generates its own keypair.
+	LegacyProduct string
+
+	// Algorithm is the algorithm used when generating new keys, only
+	// "rsa" is currently supported.
+	Algorithm string
+
+	// KeySize is the size, in bits, of newly generated keys.
+	KeySize int
+
+	lock sync.Mutex
 }
 
 // NewKeyManager returns a new KeyManager for your local machine using
@@ -52,6 +99,10 @@ func NewKeyManager(machineCode []byte) *KeyManager {
 	return &KeyManager{
 		MachineCode: machineCode,
 		Path:        licenseFolder,
+		Product:     DefaultKeyProduct,
+		Profile:     DefaultKeyProfile,
+		Algorithm:   DefaultKeyAlgorithm,
+		KeySize:     DefaultKeySize,
 	}
 }
 
@@ -59,48 +110,35 @@ func NewKeyManager(machineCode []byte) *KeyManager {
 // is used by upstream servers to identify and encrypt keys for your
 // machine.
 func (m *KeyManager) GetPublicKey() (*rsa.PublicKey, error) {
-	err := m.ensureKeypair()
-	if err != nil {
-		return nil, err
-	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
 
-	data, err := m.readKeyFile(PublicKeyName)
+	err := m.ensureKeypair()
 	if err != nil {
 		return nil, err
 	}
 
-	pubBlock, _ := pem.Decode(data)
-	if pubBlock == nil {
-		return nil, errors.New("machine key was not a valid PEM block")
-	}
-
-	if pubBlock.Type != PublicKeyType {
-		return nil, errors.New("machine key was not of the correct type")
-	}
-
-	pub, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+	data, err := ioutil.ReadFile(m.PublicKeyPath())
 	if err != nil {
 		return nil, err
 	}
 
-	switch pub.(type) {
-	case *rsa.PublicKey:
-		return pub.(*rsa.PublicKey), nil
-	default:
-		return nil, errors.New("only ECDSA public keys supported")
-	}
+	return ParsePublicKey(data)
 }
 
 // GetPrivateKey retrieves the private key for your local machine. This
 // is used to decrypt license packs and sign child license files for
 // later verification.
 func (m *KeyManager) GetPrivateKey() (*rsa.PrivateKey, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	err := m.ensureKeypair()
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := m.readKeyFile(PrivateKeyName)
+	data, err := ioutil.ReadFile(m.PrivateKeyPath())
 	if err != nil {
 		return nil, err
 	}
@@ -135,34 +173,140 @@ func (m *KeyManager) GetPrivateKey() (*rsa.PrivateKey, error) {
 // the existing keypair and invalidating any licenses which were created
 // for it.
 func (m *KeyManager) ResetKeypair() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
 	return m.createKeypair()
 }
 
+// HasKeypair determines whether a keypair has already been created for
+// this KeyManager's namespace.
+func (m *KeyManager) HasKeypair() bool {
+	if m.validateNamespace() != nil {
+		return false
+	}
+
+	return fileExists(m.PrivateKeyPath()) && fileExists(m.PublicKeyPath())
+}
+
+// PrivateKeyPath returns the path at which this KeyManager's private
+// key is stored.
+func (m *KeyManager) PrivateKeyPath() string {
+	return filepath.Join(m.namespacePath(), m.Profile)
+}
+
+// PublicKeyPath returns the path at which this KeyManager's public
+// key is stored.
+func (m *KeyManager) PublicKeyPath() string {
+	return filepath.Join(m.namespacePath(), fmt.Sprintf("%s.pub", m.Profile))
+}
+
+// ParsePublicKey parses a PEM encoded public key of the form produced
+// by a KeyManager.
+func ParsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	pubBlock, _ := pem.Decode(data)
+	if pubBlock == nil {
+		return nil, errors.New("machine key was not a valid PEM block")
+	}
+
+	if pubBlock.Type != PublicKeyType {
+		return nil, errors.New("machine key was not of the correct type")
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(pubBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return pub.(*rsa.PublicKey), nil
+	default:
+		return nil, errors.New("only RSA public keys supported")
+	}
+}
+
 func (m *KeyManager) ensureKeypair() error {
-	if !m.keyFileExists(PrivateKeyName) || !m.keyFileExists(PublicKeyName) {
-		return m.createKeypair()
+	err := m.validateNamespace()
+	if err != nil {
+		return err
 	}
 
-	return nil
+	if m.HasKeypair() {
+		return nil
+	}
+
+	migrated, err := m.migrateLegacyKeypair()
+	if err != nil || migrated {
+		return err
+	}
+
+	return m.createKeypair()
 }
 
-func (m *KeyManager) keyFileExists(file string) bool {
-	f, err := os.Open(m.getLicenseFilePath(file))
-	if err == nil {
-		defer f.Close()
+// migrateLegacyKeypair copies the keypair which was previously shared by
+// all products into the namespace of the LegacyProduct, ensuring that
+// licenses issued for the original key remain usable. Other products are
+// not given the legacy keypair, so that no two products share a key.
+func (m *KeyManager) migrateLegacyKeypair() (bool, error) {
+	legacyProduct := m.LegacyProduct
+	if legacyProduct == "" {
+		legacyProduct = DefaultKeyProduct
 	}
 
-	if err != nil && os.IsNotExist(err) {
-		return false
-	} else if err != nil {
-		return false
+	if m.Product != legacyProduct || m.Profile != DefaultKeyProfile {
+		return false, nil
+	}
+
+	legacyPrivate := filepath.Join(m.Path, legacyPrivateKeyName)
+	legacyPublic := filepath.Join(m.Path, legacyPublicKeyName)
+	if !fileExists(legacyPrivate) || !fileExists(legacyPublic) {
+		return false, nil
+	}
+
+	privData, err := ioutil.ReadFile(legacyPrivate)
+	if err != nil {
+		return false, err
+	}
+
+	pubData, err := ioutil.ReadFile(legacyPublic)
+	if err != nil {
+		return false, err
+	}
+
+	err = m.writeKeyFile(m.PrivateKeyPath(), privData)
+	if err != nil {
+		return false, err
 	}
 
-	return true
+	err = m.writeKeyFile(m.PublicKeyPath(), pubData)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
 }
 
 func (m *KeyManager) createKeypair() error {
-	priv, err := rsa.GenerateKey(rand.Reader, KeySize)
+	if len(m.MachineCode) == 0 {
+		return ErrNoMachineCode
+	}
+
+	algorithm := m.Algorithm
+	if algorithm == "" {
+		algorithm = DefaultKeyAlgorithm
+	}
+
+	if strings.ToLower(algorithm) != "rsa" {
+		return fmt.Errorf("unsupported key algorithm '%s'", algorithm)
+	}
+
+	keySize := m.KeySize
+	if keySize == 0 {
+		keySize = DefaultKeySize
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, keySize)
 	if err != nil {
 		return err
 	}
@@ -176,8 +320,11 @@ func (m *KeyManager) createKeypair() error {
 		m.MachineCode,
 		x509.PEMCipherAES256,
 	)
+	if err != nil {
+		return err
+	}
 
-	err = m.writeKeyFile(PrivateKeyName, pem.EncodeToMemory(encryptedPrivateKey))
+	err = m.writeKeyFile(m.PrivateKeyPath(), pem.EncodeToMemory(encryptedPrivateKey))
 	if err != nil {
 		return err
 	}
@@ -193,30 +340,47 @@ func (m *KeyManager) createKeypair() error {
 		Bytes: pubKeyData,
 	})
 
-	return m.writeKeyFile(PublicKeyName, pubKeyBytes)
+	return m.writeKeyFile(m.PublicKeyPath(), pubKeyBytes)
 }
 
 func (m *KeyManager) writeKeyFile(file string, data []byte) error {
-	f, err := os.Create(m.getLicenseFilePath(file))
+	err := m.validateNamespace()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	_, err = f.Write(data)
-	return err
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0600)
 }
 
-func (m *KeyManager) readKeyFile(file string) ([]byte, error) {
-	f, err := os.Open(m.getLicenseFilePath(file))
-	if err != nil {
-		return nil, err
+func (m *KeyManager) namespacePath() string {
+	return filepath.Join(m.Path, "keys", m.Product)
+}
+
+// validateNamespace ensures that the Product and Profile may be used as
+// the names of folders and files beneath Path, so that a KeyManager cannot
+// read or write keys outside of its own namespace.
+func (m *KeyManager) validateNamespace() error {
+	if !validKeyName(m.Product) {
+		return fmt.Errorf("invalid key product '%s', expected a name without path separators", m.Product)
+	}
+
+	if !validKeyName(m.Profile) {
+		return fmt.Errorf("invalid key profile '%s', expected a name without path separators", m.Profile)
 	}
-	defer f.Close()
 
-	return ioutil.ReadAll(f)
+	return nil
+}
+
+func validKeyName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`+"\x00")
 }
 
-func (m *KeyManager) getLicenseFilePath(file string) string {
-	return filepath.Join(m.Path, file)
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return err == nil
 }
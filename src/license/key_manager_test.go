@@ -10,7 +10,6 @@ import (
 )
 
 func TestNewKeyManager(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 
 	m := NewKeyManager(machineCode)
@@ -25,10 +24,17 @@ func TestNewKeyManager(t *testing.T) {
 	if m.Path == "" {
 		t.Errorf("expected path to be a defined value")
 	}
+
+	if m.Profile != DefaultKeyProfile {
+		t.Errorf("expected profile to be '%s', got '%s'", DefaultKeyProfile, m.Profile)
+	}
+
+	if m.KeySize != DefaultKeySize {
+		t.Errorf("expected key size to be %d, got %d", DefaultKeySize, m.KeySize)
+	}
 }
 
 func TestGetPublicKey(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -39,6 +45,7 @@ func TestGetPublicKey(t *testing.T) {
 
 	m := NewKeyManager(machineCode)
 	m.Path = testPath
+	m.KeySize = 1024
 
 	key, err := m.GetPublicKey()
 	if err != nil {
@@ -49,7 +56,7 @@ func TestGetPublicKey(t *testing.T) {
 		t.Fatalf("expected public key to be defined")
 	}
 
-	f, err := os.Open(filepath.Join(testPath, PublicKeyName))
+	f, err := os.Open(m.PublicKeyPath())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -71,7 +78,6 @@ func TestGetPublicKey(t *testing.T) {
 }
 
 func TestPublicKeyPersistence(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -82,6 +88,7 @@ func TestPublicKeyPersistence(t *testing.T) {
 
 	m := NewKeyManager(machineCode)
 	m.Path = testPath
+	m.KeySize = 1024
 
 	key, err := m.GetPublicKey()
 	if err != nil {
@@ -99,7 +106,6 @@ func TestPublicKeyPersistence(t *testing.T) {
 }
 
 func TestGetPrivateKey(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -110,6 +116,7 @@ func TestGetPrivateKey(t *testing.T) {
 
 	m := NewKeyManager(machineCode)
 	m.Path = testPath
+	m.KeySize = 1024
 
 	key, err := m.GetPrivateKey()
 	if err != nil {
@@ -129,7 +136,7 @@ func TestGetPrivateKey(t *testing.T) {
 		t.Errorf("expected key.D to be defined")
 	}
 
-	f, err := os.Open(filepath.Join(testPath, PrivateKeyName))
+	f, err := os.Open(m.PrivateKeyPath())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -151,7 +158,6 @@ func TestGetPrivateKey(t *testing.T) {
 }
 
 func TestPrivateKeyPersistence(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -162,6 +168,7 @@ func TestPrivateKeyPersistence(t *testing.T) {
 
 	m := NewKeyManager(machineCode)
 	m.Path = testPath
+	m.KeySize = 1024
 
 	key, err := m.GetPrivateKey()
 	if err != nil {
@@ -179,7 +186,6 @@ func TestPrivateKeyPersistence(t *testing.T) {
 }
 
 func TestResetKeys(t *testing.T) {
-	KeySize = 1024
 	machineCode := []byte("test")
 	tempDir := os.TempDir()
 	testPath, err := ioutil.TempDir(tempDir, "lithium")
@@ -190,6 +196,7 @@ func TestResetKeys(t *testing.T) {
 
 	m := NewKeyManager(machineCode)
 	m.Path = testPath
+	m.KeySize = 1024
 
 	key, err := m.GetPrivateKey()
 	if err != nil {
@@ -214,3 +221,216 @@ func TestResetKeys(t *testing.T) {
 		t.Error("expected a new public key to have been generated")
 	}
 }
+
+func TestKeyNamespaces(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	m1 := NewKeyManager(machineCode)
+	m1.Path = testPath
+	m1.KeySize = 1024
+	m1.Product = "product1"
+
+	m2 := NewKeyManager(machineCode)
+	m2.Path = testPath
+	m2.KeySize = 1024
+	m2.Product = "product2"
+	m2.Profile = "server"
+
+	key1, err := m1.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key2, err := m2.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(key1, key2) {
+		t.Error("expected different products to have different keys")
+	}
+
+	expectedPath := filepath.Join(testPath, "keys", "product2", "server.pub")
+	if m2.PublicKeyPath() != expectedPath {
+		t.Errorf("expected public key path to be '%s', got '%s'", expectedPath, m2.PublicKeyPath())
+	}
+
+	if key2.N.BitLen() != 1024 {
+		t.Errorf("expected a 1024 bit key, got %d bits", key2.N.BitLen())
+	}
+}
+
+func TestLegacyKeyMigration(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	legacy := NewKeyManager(machineCode)
+	legacy.Path = testPath
+	legacy.KeySize = 1024
+
+	key, err := legacy.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Rename(legacy.PrivateKeyPath(), filepath.Join(testPath, "machine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = os.Rename(legacy.PublicKeyPath(), filepath.Join(testPath, "machine.pub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewKeyManager(machineCode)
+	m.Path = testPath
+	m.KeySize = 1024
+	m.Product = "testing"
+	m.LegacyProduct = "testing"
+
+	migratedKey, err := m.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(key, migratedKey) {
+		t.Error("expected the legacy key to be migrated into the product namespace")
+	}
+
+	s := NewKeyManager(machineCode)
+	s.Path = testPath
+	s.KeySize = 1024
+	s.Product = "testing"
+	s.Profile = "server"
+	s.LegacyProduct = "testing"
+
+	serverKey, err := s.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(key, serverKey) {
+		t.Error("expected the legacy key to only be migrated into the default profile")
+	}
+
+	o := NewKeyManager(machineCode)
+	o.Path = testPath
+	o.KeySize = 1024
+	o.Product = "other"
+	o.LegacyProduct = "testing"
+
+	otherKey, err := o.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(key, otherKey) {
+		t.Error("expected the legacy key to only be migrated into the legacy product")
+	}
+}
+
+func TestKeyNamespaceValidation(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	cases := []struct {
+		product string
+		profile string
+	}{
+		{"", DefaultKeyProfile},
+		{"testing", ""},
+		{"..", DefaultKeyProfile},
+		{"../../x", DefaultKeyProfile},
+		{"a/b", DefaultKeyProfile},
+		{`a\b`, DefaultKeyProfile},
+		{"testing", ".."},
+		{"testing", "../../x"},
+		{"testing", "a/b"},
+	}
+
+	for _, c := range cases {
+		m := NewKeyManager(machineCode)
+		m.Path = testPath
+		m.KeySize = 1024
+		m.Product = c.product
+		m.Profile = c.profile
+
+		if _, err := m.GetPrivateKey(); err == nil {
+			t.Errorf("expected product '%s' and profile '%s' to be rejected", c.product, c.profile)
+		}
+
+		if m.HasKeypair() {
+			t.Errorf("expected product '%s' and profile '%s' to have no keypair", c.product, c.profile)
+		}
+
+		if _, err := m.InstalledLicenses(); err == nil {
+			t.Errorf("expected product '%s' and profile '%s' to have no licenses", c.product, c.profile)
+		}
+	}
+
+	files, err := ioutil.ReadDir(testPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 0 {
+		t.Errorf("expected no files to be written, got %d", len(files))
+	}
+}
+
+func TestUnsupportedKeyAlgorithm(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	m := NewKeyManager(machineCode)
+	m.Path = testPath
+	m.Algorithm = "dsa"
+
+	_, err = m.GetPublicKey()
+	if err == nil {
+		t.Error("expected an error for an unsupported key algorithm")
+	}
+}
+
+func TestMissingMachineCode(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	m := NewKeyManager(nil)
+	m.Path = testPath
+
+	_, err = m.GetPublicKey()
+	if err != ErrNoMachineCode {
+		t.Errorf("expected a keypair not to be generated without a machine code, got %v", err)
+	}
+
+	if m.HasKeypair() {
+		t.Error("expected no keypair to be written without a machine code")
+	}
+}
@@ -0,0 +1,133 @@
+package license
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// KeyShareType is the PEM block type used for a single share of a private
+// key which has been split using Shamir secret sharing.
+const KeyShareType = "LITHIUM KEY SHARE"
+
+// KeySharesRef is the certificate chain key reference used when the chain's
+// private key has been split into shares rather than written to a file.
+const KeySharesRef = "shares"
+
+// SplitKey splits the given private key into the requested number of PEM
+// encoded shares, any threshold of which may be provided to Unlock in order
+// to recover the key. Each share records the product it belongs to and the
+// fingerprint of the key's public half so that shares from different keys
+// are not accidentally combined.
+func (m *CertManager) SplitKey(key *rsa.PrivateKey, shares, threshold int) ([][]byte, error) {
+	fingerprint, err := KeyFingerprint(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := SplitSecret(x509.MarshalPKCS1PrivateKey(key), shares, threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]byte, len(secrets))
+	for i, secret := range secrets {
+		result[i] = pem.EncodeToMemory(&pem.Block{
+			Type: KeyShareType,
+			Headers: map[string]string{
+				"product":   m.Product.ID,
+				"key":       fingerprint,
+				"share":     strconv.Itoa(int(secret[0])),
+				"threshold": strconv.Itoa(threshold),
+			},
+			Bytes: secret,
+		})
+	}
+
+	return result, nil
+}
+
+// Unlock recombines the given PEM encoded key shares in memory, verifies
+// that the recovered key matches the local certificate and retains it for
+// use by UnlockedKey. The key is never written to disk.
+func (m *CertManager) Unlock(shares [][]byte) error {
+	key, err := m.combineKeyShares(shares)
+	if err != nil {
+		return err
+	}
+
+	cert, err := m.GetLocal()
+	if err != nil {
+		return err
+	}
+
+	if cert == nil {
+		return errors.New("no local certificate is available to verify the key against")
+	}
+
+	return m.setUnlocked(cert, key)
+}
+
+// UnlockedKey returns the private key recovered by Unlock or UnlockKey, or
+// nil if the certificate manager has not been unlocked.
+func (m *CertManager) UnlockedKey() crypto.Signer {
+	if m.unlocked == nil {
+		return nil
+	}
+
+	return m.unlocked
+}
+
+func (m *CertManager) combineKeyShares(shares [][]byte) (*rsa.PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("expected key shares to be provided")
+	}
+
+	secrets := make([][]byte, len(shares))
+	var fingerprint string
+	threshold := 0
+
+	for i, data := range shares {
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != KeyShareType {
+			return nil, fmt.Errorf("share %d is not a valid key share", i+1)
+		}
+
+		if m.Product != nil && block.Headers["product"] != m.Product.ID {
+			return nil, fmt.Errorf("share %d belongs to a different product", i+1)
+		}
+
+		if i == 0 {
+			fingerprint = block.Headers["key"]
+			threshold, _ = strconv.Atoi(block.Headers["threshold"])
+		} else if block.Headers["key"] != fingerprint {
+			return nil, fmt.Errorf("share %d belongs to a different key", i+1)
+		}
+
+		secrets[i] = block.Bytes
+	}
+
+	if len(secrets) < threshold {
+		return nil, fmt.Errorf("expected at least %d shares to be provided, got %d", threshold, len(secrets))
+	}
+
+	secret, err := CombineSecret(secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(secret)
+	if err != nil {
+		return nil, errors.New("the key shares could not be combined into a valid key")
+	}
+
+	if actual, err := KeyFingerprint(key.Public()); err != nil || actual != fingerprint {
+		return nil, errors.New("the key shares could not be combined into a valid key")
+	}
+
+	return key, nil
+}
@@ -0,0 +1,91 @@
+package license
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestCertManUnlock(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetChain(&Chain{
+		Certificates: []*x509.Certificate{rootCert},
+		KeyRef:       KeySharesRef,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shares, err := cm.SplitKey(rootKey, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cm.UnlockedKey() != nil {
+		t.Fatal("expected the certificate manager to start locked")
+	}
+
+	err = cm.Unlock(shares[:1])
+	if err == nil {
+		t.Error("expected unlocking with fewer shares than the threshold to fail")
+	}
+
+	err = cm.Unlock([][]byte{shares[2], shares[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := cm.UnlockedKey()
+	if key == nil {
+		t.Fatal("expected an unlocked key to be available")
+	}
+
+	if !rootKey.PublicKey.Equal(key.Public()) {
+		t.Error("expected the unlocked key to match the root key")
+	}
+
+	km.Profile = "other"
+	otherKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherShares, err := cm.SplitKey(otherKey, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.Unlock([][]byte{shares[0], otherShares[1]})
+	if err == nil {
+		t.Error("expected shares from different keys not to be combined")
+	}
+
+	err = cm.Unlock(otherShares[:2])
+	if err == nil {
+		t.Error("expected a key which does not match the local certificate to be rejected")
+	}
+}
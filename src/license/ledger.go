@@ -0,0 +1,222 @@
+package license
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// LedgerEntryKind identifies the type of item recorded by a ledger entry.
+type LedgerEntryKind string
+
+const (
+	// LedgerCertificate identifies ledger entries for issued certificates.
+	LedgerCertificate LedgerEntryKind = "certificate"
+
+	// LedgerLicense identifies ledger entries for issued licenses.
+	LedgerLicense LedgerEntryKind = "license"
+)
+
+// serialNumberLimit bounds the serial numbers allocated by a ledger to
+// 127 bits, ensuring that they remain positive and fit within the 20
+// octets permitted by RFC 5280.
+var serialNumberLimit = new(big.Int).Lsh(big.NewInt(1), 127)
+
+// LedgerEntry records a single certificate or license issued by a product.
+type LedgerEntry struct {
+	Kind LedgerEntryKind `json:"kind"`
+
+	// Issuer is the fingerprint of the issuing certificate's public key.
+	Issuer string `json:"issuer"`
+
+	// Serial is the hex encoded serial number, unique for the issuer.
+	Serial string `json:"serial"`
+
+	LicenseID string `json:"licenseId,omitempty"`
+
+	// SubjectKey is the fingerprint of the public key the certificate or
+	// license was issued to.
+	SubjectKey string `json:"subjectKey"`
+
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+
+	// PayloadHash is the hex encoded SHA-256 hash of the license payload.
+	PayloadHash string `json:"payloadHash,omitempty"`
+
+	IssuedAt time.Time `json:"issuedAt"`
+}
+
+// Ledger records every certificate and license issued by a product and
+// allocates the serial numbers used to identify them.
+type Ledger interface {
+	// ReserveSerial allocates a serial number which has not previously been
+	// used by the given issuer.
+	ReserveSerial(issuer string) (*big.Int, error)
+
+	// Record adds an entry to the ledger.
+	Record(entry *LedgerEntry) error
+
+	// Entries retrieves all of the entries recorded in the ledger.
+	Entries() ([]*LedgerEntry, error)
+}
+
+// MemoryLedger is a Ledger which holds its entries in memory. It is
+// primarily intended for testing and as a base for other backends.
+type MemoryLedger struct {
+	entries  []*LedgerEntry
+	serials  map[string]bool
+	recorded map[string]bool
+	lock     sync.Mutex
+	onRecord func(entry *LedgerEntry) error
+}
+
+// NewMemoryLedger creates a new, empty, in-memory ledger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{
+		entries:  []*LedgerEntry{},
+		serials:  map[string]bool{},
+		recorded: map[string]bool{},
+	}
+}
+
+// ReserveSerial allocates a random serial number which has not previously
+// been used by the given issuer.
+func (l *MemoryLedger) ReserveSerial(issuer string) (*big.Int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for {
+		serial, err := rand.Int(rand.Reader, serialNumberLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		if serial.Sign() == 0 {
+			continue
+		}
+
+		key := serialKey(issuer, serial.Text(16))
+		if l.serials[key] {
+			continue
+		}
+
+		l.serials[key] = true
+		return serial, nil
+	}
+}
+
+// Record adds an entry to the ledger, failing if its serial number has
+// already been recorded for the same issuer.
+func (l *MemoryLedger) Record(entry *LedgerEntry) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	key := serialKey(entry.Issuer, entry.Serial)
+	if l.recorded[key] {
+		return errors.New("serial number has already been issued")
+	}
+
+	if l.onRecord != nil {
+		err := l.onRecord(entry)
+		if err != nil {
+			return err
+		}
+	}
+
+	l.entries = append(l.entries, entry)
+	l.serials[key] = true
+	l.recorded[key] = true
+	return nil
+}
+
+// Entries retrieves all of the entries recorded in the ledger.
+func (l *MemoryLedger) Entries() ([]*LedgerEntry, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	entries := make([]*LedgerEntry, len(l.entries))
+	copy(entries, l.entries)
+	return entries, nil
+}
+
+// FileLedger is a Ledger which persists its entries to a local file, with
+// one JSON encoded entry per line. Entries are loaded when the ledger is
+// opened and appended as they are recorded.
+type FileLedger struct {
+	*MemoryLedger
+	Path string
+}
+
+// OpenFileLedger opens, or creates, the ledger stored at the given path.
+func OpenFileLedger(path string) (*FileLedger, error) {
+	l := &FileLedger{
+		MemoryLedger: NewMemoryLedger(),
+		Path:         path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry LedgerEntry
+		err := json.Unmarshal(line, &entry)
+		if err != nil {
+			return nil, err
+		}
+
+		key := serialKey(entry.Issuer, entry.Serial)
+		l.entries = append(l.entries, &entry)
+		l.serials[key] = true
+		l.recorded[key] = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	l.onRecord = l.append
+	return l, nil
+}
+
+func (l *FileLedger) append(entry *LedgerEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(l.Path), os.ModePerm|os.ModeDir)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+func serialKey(issuer, serial string) string {
+	return issuer + "/" + serial
+}
@@ -0,0 +1,212 @@
+package license
+
+import (
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryLedgerUniqueSerials(t *testing.T) {
+	l := NewMemoryLedger()
+
+	serials := map[string]bool{}
+	lock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			serial, err := l.ReserveSerial("issuer")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if serials[serial.Text(16)] {
+				t.Errorf("serial %s was allocated twice", serial.Text(16))
+			}
+
+			serials[serial.Text(16)] = true
+		}()
+	}
+
+	wg.Wait()
+
+	err := l.Record(&LedgerEntry{Issuer: "issuer", Serial: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Record(&LedgerEntry{Issuer: "issuer", Serial: "1"})
+	if err == nil {
+		t.Error("expected recording a duplicate serial to fail")
+	}
+
+	err = l.Record(&LedgerEntry{Issuer: "other", Serial: "1"})
+	if err != nil {
+		t.Error("expected serials to be unique per issuer: ", err)
+	}
+}
+
+func TestFileLedgerPersistence(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	ledgerPath := filepath.Join(testPath, "testing.ledger")
+
+	l, err := OpenFileLedger(ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = l.Record(&LedgerEntry{
+		Kind:      LedgerLicense,
+		Issuer:    "issuer",
+		Serial:    "abc",
+		LicenseID: "license1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l2, err := OpenFileLedger(ledgerPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := l2.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].LicenseID != "license1" {
+		t.Fatalf("expected the recorded entry to be loaded, got %v", entries)
+	}
+
+	err = l2.Record(&LedgerEntry{Issuer: "issuer", Serial: "abc"})
+	if err == nil {
+		t.Error("expected serials from a previous session to be rejected")
+	}
+}
+
+func TestCertManLedger(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+	cm.Ledger = NewMemoryLedger()
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	km.Profile = "client"
+	csrData, err := km.CreateCertificateRequest(pkix.Name{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csr, err := ParseCertificateRequest(csrData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template, err := cm.Prepare(csr, &Data{
+		Meta: &Metadata{
+			ID:          "cert1",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := cm.Sign(template, rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cm.IssueLicense(&Data{
+		Meta: &Metadata{
+			ID:          "license1",
+			ActivatesOn: time.Now(),
+			ExpiresOn:   time.Now().Add(time.Hour),
+		},
+		Payload: map[string]interface{}{
+			"x": 1,
+		},
+	}, clientKey, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isValid, err := c.IsValid(rootCert)
+	if !isValid {
+		t.Error("expected issued license to be valid: ", err)
+	}
+
+	entries, err := cm.Ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 ledger entries, got %d", len(entries))
+	}
+
+	if entries[0].Kind != LedgerCertificate || entries[0].Serial != cert.SerialNumber.Text(16) {
+		t.Errorf("expected the certificate to be recorded, got %#v", entries[0])
+	}
+
+	subjectKey, err := KeyFingerprint(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entries[1].Kind != LedgerLicense || entries[1].LicenseID != "license1" || entries[1].SubjectKey != subjectKey {
+		t.Errorf("expected the license to be recorded, got %#v", entries[1])
+	}
+
+	if entries[1].PayloadHash == "" {
+		t.Error("expected the license payload hash to be recorded")
+	}
+}
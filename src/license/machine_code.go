@@ -0,0 +1,147 @@
+package license
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MachineCodeSource provides the machine code used by a KeyManager to
+// protect the machine's private key.
+type MachineCodeSource interface {
+	MachineCode() ([]byte, error)
+}
+
+// MachineCodeFunc adapts a function to the MachineCodeSource interface.
+type MachineCodeFunc func() ([]byte, error)
+
+// MachineCode calls the underlying function.
+func (f MachineCodeFunc) MachineCode() ([]byte, error) {
+	return f()
+}
+
+// MachineCodeSourceFactory creates a MachineCodeSource from the argument
+// provided in a machine code source specification.
+type MachineCodeSourceFactory func(arg string) (MachineCodeSource, error)
+
+var machineCodeSources = map[string]MachineCodeSourceFactory{}
+var machineCodeSourcesLock sync.Mutex
+
+// machineIDFiles are the locations at which the operating system's
+// machine ID may be found.
+var machineIDFiles = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id",
+}
+
+func init() {
+	RegisterMachineCodeSource("static", func(arg string) (MachineCodeSource, error) {
+		return MachineCodeFunc(func() ([]byte, error) {
+			return []byte(arg), nil
+		}), nil
+	})
+
+	RegisterMachineCodeSource("env", func(arg string) (MachineCodeSource, error) {
+		if arg == "" {
+			return nil, errors.New("expected the name of an environment variable")
+		}
+
+		return MachineCodeFunc(func() ([]byte, error) {
+			code := os.Getenv(arg)
+			if code == "" {
+				return nil, fmt.Errorf("the environment variable '%s' is not set", arg)
+			}
+
+			return []byte(code), nil
+		}), nil
+	})
+
+	RegisterMachineCodeSource("file", func(arg string) (MachineCodeSource, error) {
+		if arg == "" {
+			return nil, errors.New("expected the path to a file")
+		}
+
+		return MachineCodeFunc(func() ([]byte, error) {
+			return readMachineCodeFile(arg)
+		}), nil
+	})
+
+	RegisterMachineCodeSource("machine-id", func(arg string) (MachineCodeSource, error) {
+		return MachineCodeFunc(func() ([]byte, error) {
+			for _, file := range machineIDFiles {
+				code, err := readMachineCodeFile(file)
+				if err == nil {
+					return code, nil
+				}
+			}
+
+			return nil, errors.New("no machine ID is available on this system")
+		}), nil
+	})
+}
+
+// RegisterMachineCodeSource makes a machine code source available to
+// ParseMachineCodeSource under the given scheme, replacing any source
+// previously registered with that scheme.
+func RegisterMachineCodeSource(scheme string, factory MachineCodeSourceFactory) {
+	machineCodeSourcesLock.Lock()
+	defer machineCodeSourcesLock.Unlock()
+
+	machineCodeSources[scheme] = factory
+}
+
+// MachineCodeSources lists the schemes of the registered machine code
+// sources.
+func MachineCodeSources() []string {
+	machineCodeSourcesLock.Lock()
+	defer machineCodeSourcesLock.Unlock()
+
+	schemes := []string{}
+	for scheme := range machineCodeSources {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ParseMachineCodeSource creates the machine code source described by a
+// specification of the form "scheme:argument", for example "env:CODE" or
+// "file:/etc/lithium/code". The argument may be omitted for sources which
+// do not require one, such as "machine-id".
+func ParseMachineCodeSource(spec string) (MachineCodeSource, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	arg := ""
+	if len(parts) > 1 {
+		arg = parts[1]
+	}
+
+	machineCodeSourcesLock.Lock()
+	factory, ok := machineCodeSources[parts[0]]
+	machineCodeSourcesLock.Unlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown machine code source '%s', expected one of %s", parts[0], strings.Join(MachineCodeSources(), ", "))
+	}
+
+	return factory(arg)
+}
+
+func readMachineCodeFile(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	code := bytes.TrimSpace(data)
+	if len(code) == 0 {
+		return nil, fmt.Errorf("the file '%s' does not contain a machine code", file)
+	}
+
+	return code, nil
+}
@@ -0,0 +1,67 @@
+package license
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseMachineCodeSource(t *testing.T) {
+	testPath, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	codeFile := filepath.Join(testPath, "code")
+	err = ioutil.WriteFile(codeFile, []byte("file-code\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("LITHIUM_TEST_MACHINE_CODE", "env-code")
+	defer os.Unsetenv("LITHIUM_TEST_MACHINE_CODE")
+
+	RegisterMachineCodeSource("test", func(arg string) (MachineCodeSource, error) {
+		return MachineCodeFunc(func() ([]byte, error) {
+			return []byte("custom-" + arg), nil
+		}), nil
+	})
+
+	cases := map[string]string{
+		"static:static-code":            "static-code",
+		"env:LITHIUM_TEST_MACHINE_CODE": "env-code",
+		"file:" + codeFile:              "file-code",
+		"test:code":                     "custom-code",
+	}
+
+	for spec, expected := range cases {
+		source, err := ParseMachineCodeSource(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, err := source.MachineCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(code) != expected {
+			t.Errorf("expected '%s' to provide '%s', got '%s'", spec, expected, code)
+		}
+	}
+
+	if _, err := ParseMachineCodeSource("unknown:value"); err == nil {
+		t.Error("expected an unknown source to fail")
+	}
+
+	source, err := ParseMachineCodeSource("env:LITHIUM_TEST_MISSING_CODE")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := source.MachineCode(); err == nil {
+		t.Error("expected a missing environment variable to fail")
+	}
+}
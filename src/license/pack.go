@@ -0,0 +1,226 @@
+package license
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrPackExhausted is returned when every license permitted by a pack
+// template has already been issued.
+var ErrPackExhausted = errors.New("no licenses remain for this template in the license pack")
+
+// PackCounter tracks the number of child licenses issued from each
+// template of a license's pack.
+type PackCounter interface {
+	// Consume records the issuance of a license from the named template of
+	// the given license's pack, failing with ErrPackExhausted if the limit
+	// has already been reached.
+	Consume(licenseID, template string, limit int) error
+
+	// Release reverses a previous call to Consume, for use when the license
+	// could not be issued.
+	Release(licenseID, template string) error
+
+	// Issued retrieves the number of licenses issued from the named template
+	// of the given license's pack.
+	Issued(licenseID, template string) (int, error)
+}
+
+// MemoryPackCounter is a PackCounter which holds its counts in memory. It
+// is primarily intended for testing and as a base for other backends.
+type MemoryPackCounter struct {
+	counts   map[string]int
+	lock     sync.Mutex
+	onChange func(counts map[string]int) error
+}
+
+// NewMemoryPackCounter creates a new, empty, in-memory pack counter.
+func NewMemoryPackCounter() *MemoryPackCounter {
+	return &MemoryPackCounter{
+		counts: map[string]int{},
+	}
+}
+
+// Consume records the issuance of a license from the named template of
+// the given license's pack.
+func (p *MemoryPackCounter) Consume(licenseID, template string, limit int) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := packKey(licenseID, template)
+	if p.counts[key] >= limit {
+		return ErrPackExhausted
+	}
+
+	return p.update(key, p.counts[key]+1)
+}
+
+// Release reverses a previous call to Consume.
+func (p *MemoryPackCounter) Release(licenseID, template string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	key := packKey(licenseID, template)
+	if p.counts[key] == 0 {
+		return errors.New("no licenses have been issued for this template")
+	}
+
+	return p.update(key, p.counts[key]-1)
+}
+
+// Issued retrieves the number of licenses issued from the named template
+// of the given license's pack.
+func (p *MemoryPackCounter) Issued(licenseID, template string) (int, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.counts[packKey(licenseID, template)], nil
+}
+
+func (p *MemoryPackCounter) update(key string, count int) error {
+	previous := p.counts[key]
+	p.counts[key] = count
+
+	if p.onChange != nil {
+		err := p.onChange(p.counts)
+		if err != nil {
+			p.counts[key] = previous
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FilePackCounter is a PackCounter which persists its counts to a local
+// JSON file, rewriting it whenever a count changes.
+type FilePackCounter struct {
+	*MemoryPackCounter
+	Path string
+}
+
+// OpenFilePackCounter opens, or creates, the pack counter stored at the
+// given path.
+func OpenFilePackCounter(path string) (*FilePackCounter, error) {
+	p := &FilePackCounter{
+		MemoryPackCounter: NewMemoryPackCounter(),
+		Path:              path,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if len(data) > 0 {
+		err = json.Unmarshal(data, &p.counts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	p.onChange = p.save
+	return p, nil
+}
+
+func (p *FilePackCounter) save(counts map[string]int) error {
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(p.Path), os.ModePerm|os.ModeDir)
+	if err != nil {
+		return err
+	}
+
+	// Replace the file atomically so that a failed write cannot reset the
+	// counts of every pack.
+	tmp := p.Path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, p.Path)
+}
+
+func packKey(licenseID, template string) string {
+	return licenseID + "/" + template
+}
+
+// IssueSublicense issues a child license from the named template of a
+// parent license's pack, consuming one of the licenses permitted by the
+// template. The child carries the template's payload and nested pack, and
+// expires no later than its parent. The container is encrypted for the
+// given public key and signed using the local certificate.
+func (m *CertManager) IssueSublicense(parent *Data, template string, pubKey *rsa.PublicKey, privKey crypto.Signer, algorithm string) (*Data, *Container, error) {
+	if m.Packs == nil {
+		return nil, nil, errors.New("no pack counter is available to track issued licenses")
+	}
+
+	if parent == nil || parent.Meta == nil {
+		return nil, nil, errors.New("expected parent license metadata to be provided")
+	}
+
+	tmpl, ok := parent.Meta.Pack[template]
+	if !ok || tmpl == nil {
+		return nil, nil, fmt.Errorf("the license pack does not include the template '%s'", template)
+	}
+
+	now := time.Now()
+	if now.After(parent.Meta.ExpiresOn) {
+		return nil, nil, errors.New("the parent license has expired")
+	}
+
+	id, err := NewID()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	activatesOn := parent.Meta.ActivatesOn
+	if now.After(activatesOn) {
+		activatesOn = now
+	}
+
+	child := &Data{
+		Meta: &Metadata{
+			ID:          id,
+			ActivatesOn: activatesOn,
+			ExpiresOn:   parent.Meta.ExpiresOn,
+			Pack:        tmpl.Pack,
+			Product:     parent.Meta.Product,
+			Template:    template,
+			Parent:      parent.Meta.ID,
+		},
+		Payload: map[string]interface{}{},
+	}
+
+	for k, v := range tmpl.Payload {
+		child.Payload[k] = v
+	}
+
+	err = m.Packs.Consume(parent.Meta.ID, template, tmpl.Count)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := m.IssueLicense(child, pubKey, privKey, algorithm)
+	if err != nil {
+		if releaseErr := m.Packs.Release(parent.Meta.ID, template); releaseErr != nil {
+			return nil, nil, fmt.Errorf("%s (the consumed %s pack slot could not be released: %s)", err, template, releaseErr)
+		}
+
+		return nil, nil, err
+	}
+
+	return child, c, nil
+}
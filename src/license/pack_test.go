@@ -0,0 +1,183 @@
+package license
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFilePackCounterPersistence(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	file := filepath.Join(testPath, "test.packs")
+	p, err := OpenFilePackCounter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = p.Consume("license1", "standard", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = p.Consume("license1", "standard", 2)
+	if err != ErrPackExhausted {
+		t.Errorf("expected the pack to be exhausted, got %v", err)
+	}
+
+	err = p.Release("license1", "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err = OpenFilePackCounter(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issued, err := p.Issued("license1", "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if issued != 1 {
+		t.Errorf("expected 1 license to have been issued, got %d", issued)
+	}
+
+	issued, err = p.Issued("license2", "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if issued != 0 {
+		t.Errorf("expected counts to be tracked per license, got %d", issued)
+	}
+}
+
+func TestCertManIssueSublicense(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+	cm.Packs = NewMemoryPackCounter()
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetLocal(rootCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientKey, err := km.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := &Data{
+		Meta: &Metadata{
+			ID:          "distributor",
+			ActivatesOn: time.Now().Add(-time.Hour),
+			ExpiresOn:   time.Now().Add(time.Hour),
+			Pack: map[string]*Template{
+				"reseller": {
+					Count:   1,
+					Payload: map[string]interface{}{"tier": "reseller"},
+					Pack: map[string]*Template{
+						"standard": {
+							Count:   2,
+							Payload: map[string]interface{}{"seats": 1.0},
+						},
+					},
+				},
+			},
+		},
+		Payload: map[string]interface{}{},
+	}
+
+	reseller, c, err := cm.IssueSublicense(parent, "reseller", clientKey, rootKey, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if isValid, err := c.IsValid(rootCert); !isValid {
+		t.Fatal(err)
+	}
+
+	if reseller.Meta.Template != "reseller" || reseller.Payload["tier"] != "reseller" {
+		t.Errorf("expected the child license to be issued from the template, got %#v", reseller.Meta)
+	}
+
+	if !reseller.Meta.ExpiresOn.Equal(parent.Meta.ExpiresOn) {
+		t.Error("expected the child license to expire with its parent")
+	}
+
+	if _, ok := reseller.Meta.Pack["standard"]; !ok {
+		t.Error("expected the child license to carry the template's nested pack")
+	}
+
+	_, _, err = cm.IssueSublicense(parent, "reseller", clientKey, rootKey, "sha256")
+	if err != ErrPackExhausted {
+		t.Errorf("expected the reseller template to be exhausted, got %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, _, err = cm.IssueSublicense(reseller, "standard", clientKey, rootKey, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, _, err = cm.IssueSublicense(reseller, "standard", clientKey, rootKey, "sha256")
+	if err != ErrPackExhausted {
+		t.Errorf("expected the nested standard template to be exhausted, got %v", err)
+	}
+
+	_, _, err = cm.IssueSublicense(parent, "unknown", clientKey, rootKey, "sha256")
+	if err == nil {
+		t.Error("expected issuing from an unknown template to fail")
+	}
+
+	cm.Packs = &unreleasablePackCounter{NewMemoryPackCounter()}
+	_, _, err = cm.IssueSublicense(parent, "reseller", clientKey, rootKey, "md5")
+	if err == nil || !strings.Contains(err.Error(), "could not be released") {
+		t.Errorf("expected a failure to release the consumed pack slot to be reported, got %v", err)
+	}
+}
+
+// unreleasablePackCounter is a PackCounter whose consumed slots can never
+// be released.
+type unreleasablePackCounter struct {
+	*MemoryPackCounter
+}
+
+func (p *unreleasablePackCounter) Release(licenseID, template string) error {
+	return errors.New("the pack counter is read only")
+}
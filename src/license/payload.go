@@ -4,6 +4,7 @@ import (
 	"crypto"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/ecdh"
 	"crypto/rand"
 	"crypto/rsa"
 	"encoding/json"
@@ -23,6 +24,16 @@ type EncryptedPayload struct {
 	Key       []byte `json:"key"`
 	IV        []byte `json:"iv"`
 	Algorithm string `json:"algorithm"`
+
+	// ResponseKey is the X25519 public key with which activation response
+	// codes are produced by the issuer, it is only used by locked payloads
+	// and is covered by the container's signature.
+	ResponseKey []byte `json:"responseKey,omitempty"`
+
+	// Activation is the unlock key provided by an activation response code,
+	// encrypted for the activated machine's public key. It is only used by
+	// locked payloads.
+	Activation []byte `json:"activation,omitempty"`
 }
 
 // Encrypt will encrypt the provided data in a reversible manner. The data is
@@ -33,13 +44,63 @@ type EncryptedPayload struct {
 // Only someone in posession of the corresponding private key will be able to decrypt
 // the symmetric encryption key, and thereby decrypt the contents of the data.
 func (p *EncryptedPayload) Encrypt(data interface{}, pubKey *rsa.PublicKey) error {
-	rawData, err := json.Marshal(data)
+	symmetricKey := make([]byte, 32)
+	_, err := rand.Read(symmetricKey)
 	if err != nil {
 		return err
 	}
 
-	symmetricKey := make([]byte, 32)
-	_, err = rand.Read(symmetricKey)
+	asymmetricKey, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, pubKey, symmetricKey, []byte(EncryptedPayloadKeyLabel))
+	if err != nil {
+		return err
+	}
+
+	err = p.encrypt(data, symmetricKey)
+	if err != nil {
+		return err
+	}
+
+	p.Algorithm = "aes256"
+	p.Key = asymmetricKey
+	return nil
+}
+
+// Lock will encrypt the provided data, as with Encrypt, using a key derived
+// from the given unlock key rather than one protected for a specific client.
+// The unlock key and the private response key are themselves encrypted
+// using the issuer's public key, allowing only the issuer to later produce
+// activation responses for the payload.
+func (p *EncryptedPayload) Lock(data interface{}, unlockKey []byte, responseKey *ecdh.PrivateKey, issuerKey *rsa.PublicKey) error {
+	secret := append(append([]byte{}, unlockKey...), responseKey.Bytes()...)
+	asymmetricKey, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, issuerKey, secret, []byte(UnlockKeyLabel))
+	if err != nil {
+		return err
+	}
+
+	err = p.encrypt(data, lockedPayloadKey(unlockKey))
+	if err != nil {
+		return err
+	}
+
+	p.Algorithm = LockedPayloadAlgorithm
+	p.Key = asymmetricKey
+	p.ResponseKey = responseKey.PublicKey().Bytes()
+	p.Activation = nil
+	return nil
+}
+
+// signedData returns the portion of the payload which is covered by its
+// container's signature.
+func (p *EncryptedPayload) signedData() []byte {
+	if p.Algorithm != LockedPayloadAlgorithm {
+		return p.Data
+	}
+
+	return append(append([]byte{}, p.Data...), p.ResponseKey...)
+}
+
+func (p *EncryptedPayload) encrypt(data interface{}, symmetricKey []byte) error {
+	rawData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -60,29 +121,42 @@ func (p *EncryptedPayload) Encrypt(data interface{}, pubKey *rsa.PublicKey) erro
 	encryptionStream := cipher.NewCFBEncrypter(block, iv)
 	encryptionStream.XORKeyStream(encryptedData, rawData)
 
-	asymmetricKey, err := rsa.EncryptOAEP(crypto.SHA256.New(), rand.Reader, pubKey, symmetricKey, []byte(EncryptedPayloadKeyLabel))
-	if err != nil {
-		return err
-	}
-
-	p.Algorithm = "aes256"
 	p.Data = encryptedData
 	p.IV = iv
-	p.Key = asymmetricKey
 	return nil
 }
 
 // Decrypt will transform an encrypted payload into the data variable
 // you specify. This assumes that your provided private key matches the
 // public key used to encrypt the symmetric key for the encrypted payload.
+//
+// Locked payloads are decrypted using the unlock key recovered from their
+// activation, which is encrypted for the machine which activated them.
 func (p *EncryptedPayload) Decrypt(data interface{}, privKey *rsa.PrivateKey) error {
-	if p.Algorithm != "aes256" {
-		return errors.New("unsupported encryption algorithm type, expected aes256")
-	}
+	var symmetricKey []byte
+	var err error
 
-	symmetricKey, err := rsa.DecryptOAEP(crypto.SHA256.New(), rand.Reader, privKey, p.Key, []byte(EncryptedPayloadKeyLabel))
-	if err != nil {
-		return err
+	switch p.Algorithm {
+	case "aes256":
+		symmetricKey, err = rsa.DecryptOAEP(crypto.SHA256.New(), rand.Reader, privKey, p.Key, []byte(EncryptedPayloadKeyLabel))
+		if err != nil {
+			return err
+		}
+
+	case LockedPayloadAlgorithm:
+		if p.Activation == nil {
+			return ErrNotUnlocked
+		}
+
+		unlockKey, err := rsa.DecryptOAEP(crypto.SHA256.New(), rand.Reader, privKey, p.Activation, []byte(ActivationKeyLabel))
+		if err != nil {
+			return err
+		}
+
+		symmetricKey = lockedPayloadKey(unlockKey)
+
+	default:
+		return errors.New("unsupported encryption algorithm type, expected aes256")
 	}
 
 	block, err := aes.NewCipher(symmetricKey)
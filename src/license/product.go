@@ -1,9 +1,205 @@
 package license
 
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultRootValidityDays is the number of days for which a product's
+// root certificate is valid if the product does not specify otherwise.
+const DefaultRootValidityDays = 100 * 365
+
+// DefaultOrganizationalUnit is the organizational unit used within
+// certificate names if the product does not specify otherwise.
+const DefaultOrganizationalUnit = "Lithium Licensing"
+
+// DefaultRootKeyUsage is the set of key usages granted to a product's
+// root certificate if the product does not specify otherwise.
+var DefaultRootKeyUsage = []string{
+	"certSign",
+	"crlSign",
+	"digitalSignature",
+	"dataEncipherment",
+	"keyEncipherment",
+	"contentCommitment",
+}
+
+// productIDPattern restricts product IDs to characters which may be used
+// safely within file names and URIs.
+var productIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+var keyUsageNames = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"certSign":          x509.KeyUsageCertSign,
+	"crlSign":           x509.KeyUsageCRLSign,
+}
+
 // Product tracks a product which makes use of Lithium for licensing
-// purposes.
+// purposes. Besides identifying the product, it describes how the
+// product's certificates should be named.
 type Product struct {
 	ID           string `json:"id"`
 	Name         string `json:"name"`
 	Organization string `json:"organization"`
+
+	OrganizationalUnit string `json:"organizationalUnit,omitempty"`
+	Locality           string `json:"locality,omitempty"`
+	Province           string `json:"province,omitempty"`
+	Country            string `json:"country,omitempty"`
+
+	// RootValidityDays is the number of days for which the product's
+	// root certificate will be valid.
+	RootValidityDays int `json:"rootValidityDays,omitempty"`
+
+	// KeyUsage lists the key usages granted to the product's root
+	// certificate, for example "certSign" or "digitalSignature".
+	KeyUsage []string `json:"keyUsage,omitempty"`
+
+	// DNSNames and URIs are the subject alternative names included in
+	// the product's root certificate.
+	DNSNames []string `json:"dnsNames,omitempty"`
+	URIs     []string `json:"uris,omitempty"`
+}
+
+// Validate ensures that the product's definition is complete and that its
+// certificate settings may be used to create a root certificate.
+func (p *Product) Validate() error {
+	if p.ID == "" {
+		return errors.New("expected the product to have an ID")
+	}
+
+	if !productIDPattern.MatchString(p.ID) {
+		return fmt.Errorf("invalid product ID '%s', only letters, numbers, '-' and '_' are permitted", p.ID)
+	}
+
+	if p.Name == "" {
+		return errors.New("expected the product to have a name")
+	}
+
+	if p.Organization == "" {
+		return errors.New("expected the product to have an organization")
+	}
+
+	if p.RootValidityDays < 0 {
+		return errors.New("expected the root certificate validity to be a positive number of days")
+	}
+
+	_, err := p.RootKeyUsage()
+	if err != nil {
+		return err
+	}
+
+	_, err = p.RootURIs()
+	return err
+}
+
+// RootValidity returns the period for which the product's root
+// certificate will be valid.
+func (p *Product) RootValidity() time.Duration {
+	days := p.RootValidityDays
+	if days <= 0 {
+		days = DefaultRootValidityDays
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RootKeyUsage returns the key usages which should be granted to the
+// product's root certificate.
+func (p *Product) RootKeyUsage() (x509.KeyUsage, error) {
+	names := p.KeyUsage
+	if len(names) == 0 {
+		names = DefaultRootKeyUsage
+	}
+
+	return parseKeyUsage(names)
+}
+
+// RootURIs returns the parsed URIs which should be included in the
+// product's root certificate.
+func (p *Product) RootURIs() ([]*url.URL, error) {
+	uris := make([]*url.URL, len(p.URIs))
+	for i, u := range p.URIs {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI '%s': %s", u, err)
+		}
+
+		uris[i] = parsed
+	}
+
+	return uris, nil
+}
+
+// subject returns the certificate name used to identify the product,
+// with the given serial number.
+func (p *Product) subject(serialNumber string) pkix.Name {
+	name := pkix.Name{
+		CommonName:         fmt.Sprintf("%s (%s)", p.Name, p.ID),
+		SerialNumber:       serialNumber,
+		Organization:       []string{p.Organization},
+		OrganizationalUnit: []string{p.organizationalUnit()},
+	}
+
+	p.applyLocation(&name)
+	return name
+}
+
+// issuer returns the certificate name of the organization which issues
+// the product's licenses.
+func (p *Product) issuer() pkix.Name {
+	name := pkix.Name{
+		CommonName:         fmt.Sprintf("%s Lithium License Protocol", p.Organization),
+		Organization:       []string{p.Organization},
+		OrganizationalUnit: []string{p.organizationalUnit()},
+	}
+
+	p.applyLocation(&name)
+	return name
+}
+
+func (p *Product) organizationalUnit() string {
+	if p.OrganizationalUnit == "" {
+		return DefaultOrganizationalUnit
+	}
+
+	return p.OrganizationalUnit
+}
+
+func (p *Product) applyLocation(name *pkix.Name) {
+	if p.Locality != "" {
+		name.Locality = []string{p.Locality}
+	}
+
+	if p.Province != "" {
+		name.Province = []string{p.Province}
+	}
+
+	if p.Country != "" {
+		name.Country = []string{p.Country}
+	}
+}
+
+func parseKeyUsage(names []string) (x509.KeyUsage, error) {
+	var usage x509.KeyUsage
+	for _, name := range names {
+		u, ok := keyUsageNames[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown key usage '%s'", name)
+		}
+
+		usage |= u
+	}
+
+	return usage, nil
 }
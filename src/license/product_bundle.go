@@ -0,0 +1,106 @@
+package license
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+)
+
+// ProductType is used to armour a product's definition when it is exported
+// alongside its certificate chain.
+const ProductType = "LITHIUM PRODUCT"
+
+// EncodeProductBundle produces a bundle containing a product's definition
+// and, if provided, its certificate chain. Private keys are never included.
+func EncodeProductBundle(product *Product, chain *Chain) ([]byte, error) {
+	if product == nil {
+		return nil, errors.New("expected a product to be provided")
+	}
+
+	data, err := json.Marshal(product)
+	if err != nil {
+		return nil, err
+	}
+
+	d := pem.EncodeToMemory(&pem.Block{
+		Type:  ProductType,
+		Bytes: data,
+	})
+
+	if chain != nil {
+		chainData, err := EncodeChain(&Chain{Certificates: chain.Certificates})
+		if err != nil {
+			return nil, err
+		}
+
+		d = append(d, chainData...)
+	}
+
+	return d, nil
+}
+
+// ParseProductBundle reads the product definition and certificate chain
+// from a bundle produced by EncodeProductBundle. The product is validated
+// and, if a chain is present, it must be bound to the same product. A nil
+// chain is returned if the bundle does not contain one.
+func ParseProductBundle(data []byte) (*Product, *Chain, error) {
+	var product *Product
+	hasCertificates := false
+
+	d := data
+	for {
+		block, rest := pem.Decode(d)
+		if block == nil {
+			break
+		}
+
+		d = rest
+
+		switch block.Type {
+		case ProductType:
+			var p Product
+			err := json.Unmarshal(block.Bytes, &p)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			product = &p
+		case CertificateType, pemCertificateType:
+			hasCertificates = true
+		}
+	}
+
+	if product == nil {
+		return nil, nil, errors.New("expected the bundle to contain a product")
+	}
+
+	err := product.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !hasCertificates {
+		return product, nil, nil
+	}
+
+	chain, err := ParseChain(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = chain.Verify()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	binding, err := chainBinding(chain.Certificates)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if binding != nil && binding.ProductID != product.ID {
+		return nil, nil, errors.New("the certificate chain belongs to a different product")
+	}
+
+	return product, chain, nil
+}
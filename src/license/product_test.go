@@ -0,0 +1,204 @@
+package license
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestProductDefaults(t *testing.T) {
+	p := Product{
+		ID:           "testing",
+		Name:         "Lithium Testing",
+		Organization: "Example Corp",
+	}
+
+	if p.RootValidity() != DefaultRootValidityDays*24*time.Hour {
+		t.Errorf("expected default root validity, got %s", p.RootValidity())
+	}
+
+	usage, err := p.RootKeyUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usage&x509.KeyUsageCertSign == 0 {
+		t.Error("expected the default key usage to permit certificate signing")
+	}
+
+	issuer := p.issuer()
+	if issuer.Organization[0] != "Example Corp" {
+		t.Errorf("expected issuer organization to be 'Example Corp', got '%s'", issuer.Organization[0])
+	}
+
+	if len(issuer.Country) != 0 || len(issuer.Province) != 0 {
+		t.Error("expected issuer location to be omitted when not configured")
+	}
+}
+
+func TestProductInvalidKeyUsage(t *testing.T) {
+	p := Product{
+		KeyUsage: []string{"certSign", "flying"},
+	}
+
+	_, err := p.RootKeyUsage()
+	if err == nil {
+		t.Error("expected an error for an unknown key usage")
+	}
+}
+
+func TestCertManCreateRootFromProduct(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+
+	key, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm := NewCertManager(&Product{
+		ID:                 "widget",
+		Name:               "Widget",
+		Organization:       "Example Corp",
+		OrganizationalUnit: "Licensing",
+		Locality:           "Springfield",
+		Country:            "US",
+		RootValidityDays:   30,
+		KeyUsage:           []string{"certSign", "digitalSignature"},
+		DNSNames:           []string{"licensing.example.com"},
+		URIs:               []string{"https://licensing.example.com"},
+	})
+
+	cert, err := cm.CreateRoot(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cert.Issuer.Organization[0] != "Example Corp" {
+		t.Errorf("expected issuer organization to be 'Example Corp', got '%s'", cert.Issuer.Organization[0])
+	}
+
+	if cert.Subject.Country[0] != "US" || cert.Subject.Locality[0] != "Springfield" {
+		t.Errorf("expected subject location to be taken from the product, got %v", cert.Subject)
+	}
+
+	if cert.Subject.OrganizationalUnit[0] != "Licensing" {
+		t.Errorf("expected OU='Licensing', got '%s'", cert.Subject.OrganizationalUnit[0])
+	}
+
+	if cert.NotAfter.After(time.Now().Add(31 * 24 * time.Hour)) {
+		t.Errorf("expected root certificate to expire within 30 days, got %s", cert.NotAfter)
+	}
+
+	if cert.KeyUsage != x509.KeyUsageCertSign|x509.KeyUsageDigitalSignature {
+		t.Errorf("expected key usage to be taken from the product, got %v", cert.KeyUsage)
+	}
+
+	if len(cert.DNSNames) != 1 || cert.DNSNames[0] != "licensing.example.com" {
+		t.Errorf("expected DNS names to be taken from the product, got %v", cert.DNSNames)
+	}
+
+	if len(cert.URIs) != 1 || cert.URIs[0].Host != "licensing.example.com" {
+		t.Errorf("expected URIs to be taken from the product, got %v", cert.URIs)
+	}
+}
+
+func TestProductValidate(t *testing.T) {
+	p := Product{
+		ID:           "testing",
+		Name:         "Lithium Testing",
+		Organization: "Example Corp",
+	}
+
+	err := p.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[string]Product{
+		"missing ID":        {Name: "Test", Organization: "Example Corp"},
+		"path in ID":        {ID: "../test", Name: "Test", Organization: "Example Corp"},
+		"missing name":      {ID: "test", Organization: "Example Corp"},
+		"missing org":       {ID: "test", Name: "Test"},
+		"unknown key usage": {ID: "test", Name: "Test", Organization: "Example Corp", KeyUsage: []string{"flying"}},
+	}
+
+	for name, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected product with %s to be invalid", name)
+		}
+	}
+}
+
+func TestProductBundle(t *testing.T) {
+	testPath, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager([]byte("test"))
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+
+	key, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := EncodeProductBundle(testProduct, &Chain{
+		Certificates: []*x509.Certificate{rootCert},
+		KeyRef:       "testing.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	product, chain, err := ParseProductBundle(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if product.ID != testProduct.ID || product.Name != testProduct.Name {
+		t.Errorf("expected the product to be restored, got %#v", product)
+	}
+
+	if chain == nil || !chain.Root().Equal(rootCert) {
+		t.Error("expected the certificate chain to be restored")
+	}
+
+	if chain != nil && chain.KeyRef != "" {
+		t.Error("expected the key reference not to be exported")
+	}
+
+	other := *testProduct
+	other.ID = "other"
+	data, err = EncodeProductBundle(&other, &Chain{
+		Certificates: []*x509.Certificate{rootCert},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = ParseProductBundle(data)
+	if err == nil {
+		t.Error("expected a chain for a different product to be rejected")
+	}
+}
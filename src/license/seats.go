@@ -0,0 +1,195 @@
+package license
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNoSeats is returned when a seat is requested from a pool whose seats
+// are all checked out.
+var ErrNoSeats = errors.New("no seats are available")
+
+// ErrSeatNotHeld is returned when a seat is renewed or released by a
+// client which does not hold it, including once it has expired and been
+// returned to the pool.
+var ErrSeatNotHeld = errors.New("the seat is not held by this client")
+
+// Seat is a floating license checked out from a SeatPool. Each seat is
+// identified by the ID of the license issued for it and is bound to the
+// client which checked it out.
+type Seat struct {
+	LicenseID    string    `json:"licenseId"`
+	Client       string    `json:"client"`
+	Template     string    `json:"template,omitempty"`
+	CheckedOutOn time.Time `json:"checkedOut"`
+	ExpiresOn    time.Time `json:"expires"`
+}
+
+// SeatPool tracks the floating licenses checked out for a product. Seats
+// are leased for a short period and must be renewed by the client which
+// holds them before their lease expires, otherwise they are returned to
+// the pool. It is safe for concurrent use.
+type SeatPool struct {
+	// Size is the number of seats in the pool.
+	Size int
+
+	// Lease is the period for which seats are checked out or renewed.
+	Lease time.Duration
+
+	// Now, if provided, is used in place of time.Now.
+	Now func() time.Time
+
+	seats map[string]*Seat
+	lock  sync.Mutex
+}
+
+// NewSeatPool creates a pool with the given number of seats, each leased
+// for the given period.
+func NewSeatPool(size int, lease time.Duration) *SeatPool {
+	return &SeatPool{
+		Size:  size,
+		Lease: lease,
+		seats: map[string]*Seat{},
+	}
+}
+
+// Checkout leases a seat to the given client, identified by the
+// fingerprint of its public key, under a new license ID.
+func (p *SeatPool) Checkout(client, template string) (*Seat, error) {
+	id, err := NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	p.reclaim(now)
+
+	if len(p.seats) >= p.Size {
+		return nil, ErrNoSeats
+	}
+
+	seat := &Seat{
+		LicenseID:    id,
+		Client:       client,
+		Template:     template,
+		CheckedOutOn: now,
+		ExpiresOn:    now.Add(p.Lease),
+	}
+
+	p.seats[id] = seat
+	return seat.copy(), nil
+}
+
+// Renew extends the lease of a seat held by the given client.
+func (p *SeatPool) Renew(licenseID, client string) (*Seat, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := p.now()
+	p.reclaim(now)
+
+	seat, ok := p.seats[licenseID]
+	if !ok || seat.Client != client {
+		return nil, ErrSeatNotHeld
+	}
+
+	seat.ExpiresOn = now.Add(p.Lease)
+	return seat.copy(), nil
+}
+
+// Release returns a seat held by the given client to the pool.
+func (p *SeatPool) Release(licenseID, client string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.reclaim(p.now())
+
+	seat, ok := p.seats[licenseID]
+	if !ok || seat.Client != client {
+		return ErrSeatNotHeld
+	}
+
+	delete(p.seats, licenseID)
+	return nil
+}
+
+// Reclaim returns every expired seat to the pool, reporting how many were
+// reclaimed. Expired seats are also reclaimed whenever the pool is used.
+func (p *SeatPool) Reclaim() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.reclaim(p.now())
+}
+
+// Seats retrieves the seats which are currently checked out, ordered by
+// the time at which they were checked out.
+func (p *SeatPool) Seats() []*Seat {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.reclaim(p.now())
+
+	seats := []*Seat{}
+	for _, seat := range p.seats {
+		seats = append(seats, seat.copy())
+	}
+
+	sort.Slice(seats, func(i, j int) bool {
+		if seats[i].CheckedOutOn.Equal(seats[j].CheckedOutOn) {
+			return seats[i].LicenseID < seats[j].LicenseID
+		}
+
+		return seats[i].CheckedOutOn.Before(seats[j].CheckedOutOn)
+	})
+
+	return seats
+}
+
+// Available returns the number of seats which may be checked out.
+func (p *SeatPool) Available() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.reclaim(p.now())
+
+	if available := p.Size - len(p.seats); available > 0 {
+		return available
+	}
+
+	return 0
+}
+
+func (p *SeatPool) reclaim(now time.Time) int {
+	if p.seats == nil {
+		p.seats = map[string]*Seat{}
+	}
+
+	reclaimed := 0
+	for id, seat := range p.seats {
+		if !now.Before(seat.ExpiresOn) {
+			delete(p.seats, id)
+			reclaimed++
+		}
+	}
+
+	return reclaimed
+}
+
+func (p *SeatPool) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+
+	return time.Now()
+}
+
+func (s *Seat) copy() *Seat {
+	c := *s
+	return &c
+}
@@ -0,0 +1,126 @@
+package license
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSeatPool(t *testing.T) {
+	now := time.Now()
+	pool := NewSeatPool(2, time.Minute)
+	pool.Now = func() time.Time { return now }
+
+	a, err := pool.Checkout("client-a", "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Client != "client-a" || a.Template != "standard" || !a.ExpiresOn.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the seat to be leased to client-a for a minute, got %+v", a)
+	}
+
+	b, err := pool.Checkout("client-b", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.LicenseID == b.LicenseID {
+		t.Error("expected each seat to be given a unique license ID")
+	}
+
+	if _, err := pool.Checkout("client-c", ""); err != ErrNoSeats {
+		t.Errorf("expected checkout from a full pool to fail with ErrNoSeats, got %v", err)
+	}
+
+	if _, err := pool.Renew(a.LicenseID, "client-b"); err != ErrSeatNotHeld {
+		t.Errorf("expected a seat to only be renewed by its own client, got %v", err)
+	}
+
+	if err := pool.Release(a.LicenseID, "client-b"); err != ErrSeatNotHeld {
+		t.Errorf("expected a seat to only be released by its own client, got %v", err)
+	}
+
+	now = now.Add(45 * time.Second)
+	renewed, err := pool.Renew(a.LicenseID, "client-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !renewed.ExpiresOn.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the renewed seat to be leased for another minute, got %s", renewed.ExpiresOn)
+	}
+
+	now = now.Add(30 * time.Second)
+	if pool.Available() != 1 {
+		t.Errorf("expected the unrenewed seat to be reclaimed once it expired, got %d available", pool.Available())
+	}
+
+	if _, err := pool.Renew(b.LicenseID, "client-b"); err != ErrSeatNotHeld {
+		t.Errorf("expected an expired seat to no longer be held, got %v", err)
+	}
+
+	if err := pool.Release(a.LicenseID, "client-a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if seats := pool.Seats(); len(seats) != 0 {
+		t.Errorf("expected every seat to have been returned to the pool, got %d", len(seats))
+	}
+}
+
+func TestSeatPoolConcurrency(t *testing.T) {
+	pool := NewSeatPool(10, time.Minute)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	held := []*Seat{}
+
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			seat, err := pool.Checkout("client", "")
+			if err == ErrNoSeats {
+				return
+			}
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			lock.Lock()
+			held = append(held, seat)
+			lock.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	if len(held) != 10 {
+		t.Fatalf("expected exactly 10 seats to be checked out, got %d", len(held))
+	}
+
+	for _, seat := range held {
+		wg.Add(1)
+		go func(seat *Seat) {
+			defer wg.Done()
+
+			if _, err := pool.Renew(seat.LicenseID, "client"); err != nil {
+				t.Error(err)
+			}
+
+			if err := pool.Release(seat.LicenseID, "client"); err != nil {
+				t.Error(err)
+			}
+		}(seat)
+	}
+
+	wg.Wait()
+
+	if pool.Available() != 10 {
+		t.Errorf("expected every seat to be available once released, got %d", pool.Available())
+	}
+}
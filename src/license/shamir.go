@@ -0,0 +1,148 @@
+package license
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// The Shamir secret sharing scheme implemented here operates over GF(2^8),
+// using the AES reduction polynomial, so that each byte of the secret is
+// split independently. Every share is encoded as its x coordinate followed
+// by the y coordinate for each byte of the secret.
+
+var gfExp [510]byte
+var gfLog [256]byte
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		gfExp[i] = x
+		gfExp[i+255] = x
+		gfLog[x] = byte(i)
+
+		// Multiply by the generator, 3, within GF(2^8)
+		high := x & 0x80
+		x2 := x << 1
+		if high != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+}
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+int(gfLog[b])]
+}
+
+func gfDiv(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return gfExp[int(gfLog[a])+255-int(gfLog[b])]
+}
+
+// SplitSecret divides a secret into the given number of shares, any
+// threshold of which may be combined to recover the secret using
+// CombineSecret. Fewer than threshold shares reveal nothing about the
+// secret.
+func SplitSecret(secret []byte, shares, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("expected a secret to be provided")
+	}
+
+	if threshold < 2 {
+		return nil, errors.New("expected a threshold of at least 2 shares")
+	}
+
+	if shares < threshold {
+		return nil, errors.New("expected at least as many shares as the threshold")
+	}
+
+	if shares > 255 {
+		return nil, errors.New("at most 255 shares may be generated")
+	}
+
+	result := make([][]byte, shares)
+	for i := range result {
+		result[i] = make([]byte, len(secret)+1)
+		result[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, err
+		}
+		coefficients[0] = value
+
+		for _, share := range result {
+			share[b+1] = evaluatePolynomial(coefficients, share[0])
+		}
+	}
+
+	return result, nil
+}
+
+// CombineSecret recovers a secret from shares produced by SplitSecret. At
+// least the threshold number of shares must be provided, otherwise the
+// result will not match the original secret.
+func CombineSecret(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("expected at least 2 shares to be provided")
+	}
+
+	length := len(shares[0])
+	if length < 2 {
+		return nil, errors.New("share was not of the correct length")
+	}
+
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if len(share) != length {
+			return nil, errors.New("shares were not of the same length")
+		}
+
+		if share[0] == 0 || seen[share[0]] {
+			return nil, errors.New("shares must have unique, non-zero indices")
+		}
+
+		seen[share[0]] = true
+	}
+
+	secret := make([]byte, length-1)
+	for b := range secret {
+		var value byte
+		for i, si := range shares {
+			// Compute the Lagrange basis polynomial for this share at x = 0
+			basis := byte(1)
+			for j, sj := range shares {
+				if i == j {
+					continue
+				}
+
+				basis = gfMul(basis, gfDiv(sj[0], sj[0]^si[0]))
+			}
+
+			value ^= gfMul(si[b+1], basis)
+		}
+
+		secret[b] = value
+	}
+
+	return secret, nil
+}
+
+func evaluatePolynomial(coefficients []byte, x byte) byte {
+	result := coefficients[len(coefficients)-1]
+	for i := len(coefficients) - 2; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+
+	return result
+}
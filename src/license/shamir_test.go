@@ -0,0 +1,69 @@
+package license
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShamirSplitCombine(t *testing.T) {
+	secret := []byte("a secret which should be split into several shares")
+
+	shares, err := SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shares) != 5 {
+		t.Fatalf("expected 5 shares, got %d", len(shares))
+	}
+
+	subsets := [][][]byte{
+		{shares[0], shares[1], shares[2]},
+		{shares[4], shares[2], shares[0]},
+		{shares[1], shares[3], shares[4]},
+		shares,
+	}
+
+	for i, subset := range subsets {
+		result, err := CombineSecret(subset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(result, secret) {
+			t.Errorf("expected subset %d to recover the secret", i)
+		}
+	}
+
+	result, err := CombineSecret(shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(result, secret) {
+		t.Error("expected fewer than the threshold of shares not to recover the secret")
+	}
+}
+
+func TestShamirInvalidParameters(t *testing.T) {
+	if _, err := SplitSecret([]byte("secret"), 2, 3); err == nil {
+		t.Error("expected a threshold greater than the number of shares to fail")
+	}
+
+	if _, err := SplitSecret([]byte("secret"), 3, 1); err == nil {
+		t.Error("expected a threshold of 1 to fail")
+	}
+
+	if _, err := SplitSecret([]byte("secret"), 256, 2); err == nil {
+		t.Error("expected more than 255 shares to fail")
+	}
+
+	shares, err := SplitSecret([]byte("secret"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := CombineSecret([][]byte{shares[0], shares[0]}); err == nil {
+		t.Error("expected duplicate shares to fail")
+	}
+}
@@ -0,0 +1,123 @@
+package license
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// WriteKey encrypts a product or intermediate signing key using the given
+// passphrase and writes it, readable only by its owner, to the file named
+// by keyRef. Relative references are resolved against the manager's path.
+func (m *CertManager) WriteKey(keyRef string, key *rsa.PrivateKey, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return errors.New("expected a passphrase to protect the key")
+	}
+
+	block, err := x509.EncryptPEMBlock(
+		rand.Reader,
+		PrivateKeyType,
+		x509.MarshalPKCS1PrivateKey(key),
+		passphrase,
+		x509.PEMCipherAES256,
+	)
+	if err != nil {
+		return err
+	}
+
+	file := m.keyPath(keyRef)
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Tighten the permissions of any key file which already existed
+	err = f.Chmod(0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(pem.EncodeToMemory(block))
+	return err
+}
+
+// LoadKey reads and decrypts the signing key stored in the file named by
+// keyRef using the given passphrase. Keys written by earlier versions
+// without encryption are still accepted.
+func (m *CertManager) LoadKey(keyRef string, passphrase []byte) (*rsa.PrivateKey, error) {
+	file := m.keyPath(keyRef)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != PrivateKeyType {
+		return nil, fmt.Errorf("'%s' is not a valid private key", file)
+	}
+
+	der := block.Bytes
+	if x509.IsEncryptedPEMBlock(block) {
+		der, err = x509.DecryptPEMBlock(block, passphrase)
+		if err != nil {
+			return nil, errors.New("the passphrase could not decrypt the private key")
+		}
+	}
+
+	return x509.ParsePKCS1PrivateKey(der)
+}
+
+// UnlockKey decrypts the key referenced by the local certificate chain
+// using the given passphrase, verifies that it matches the local
+// certificate and retains it for use by UnlockedKey.
+func (m *CertManager) UnlockKey(passphrase []byte) error {
+	chain, err := m.GetChain()
+	if err != nil {
+		return err
+	}
+
+	if chain == nil {
+		return errors.New("no local certificate is available to verify the key against")
+	}
+
+	if chain.KeyRef == "" || chain.KeyRef == KeySharesRef {
+		return errors.New("the local certificate chain does not reference a key file")
+	}
+
+	key, err := m.LoadKey(chain.KeyRef, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return m.setUnlocked(chain.Leaf(), key)
+}
+
+func (m *CertManager) setUnlocked(cert *x509.Certificate, key *rsa.PrivateKey) error {
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || !pub.Equal(key.Public()) {
+		return errors.New("the key does not match the local certificate")
+	}
+
+	m.unlocked = key
+	return nil
+}
+
+func (m *CertManager) keyPath(keyRef string) string {
+	if filepath.IsAbs(keyRef) {
+		return keyRef
+	}
+
+	return filepath.Join(m.Path, keyRef)
+}
@@ -0,0 +1,137 @@
+package license
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCertManWriteKey(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(testPath, "test.key")
+	err = ioutil.WriteFile(keyFile, []byte("insecure"), 0777)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.WriteKey("test.key", rootKey, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected key file to have permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || !x509.IsEncryptedPEMBlock(block) {
+		t.Fatal("expected key file to be encrypted")
+	}
+
+	_, err = cm.LoadKey("test.key", []byte("wrong"))
+	if err == nil {
+		t.Error("expected an incorrect passphrase to fail")
+	}
+
+	key, err := cm.LoadKey("test.key", []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rootKey.Equal(key) {
+		t.Error("expected the loaded key to match the original key")
+	}
+
+	err = cm.WriteKey("test.key", rootKey, nil)
+	if err == nil {
+		t.Error("expected writing a key without a passphrase to fail")
+	}
+}
+
+func TestCertManUnlockKey(t *testing.T) {
+	machineCode := []byte("test")
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	km := NewKeyManager(machineCode)
+	km.Path = testPath
+	km.KeySize = 1024
+	cm := NewCertManager(testProduct)
+	cm.Path = testPath
+
+	rootKey, err := km.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCert, err := cm.CreateRoot(rootKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.SetChain(&Chain{
+		Certificates: []*x509.Certificate{rootCert},
+		KeyRef:       "root.key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.WriteKey("root.key", rootKey, []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cm.UnlockKey([]byte("wrong"))
+	if err == nil {
+		t.Error("expected an incorrect passphrase to fail")
+	}
+
+	if cm.UnlockedKey() != nil {
+		t.Error("expected the certificate manager to remain locked")
+	}
+
+	err = cm.UnlockKey([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key := cm.UnlockedKey(); key == nil || !rootKey.PublicKey.Equal(key.Public()) {
+		t.Error("expected the unlocked key to match the root key")
+	}
+}
@@ -0,0 +1,321 @@
+package license
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTemplateNotFound is returned when a named license template has not
+// been defined.
+var ErrTemplateNotFound = errors.New("license template not found")
+
+// LicenseTemplate is a named, reusable, description of a class of licenses
+// issued for a product, for example "trial-30d" or "enterprise". Templates
+// may extend another template, inheriting any defaults they do not
+// override themselves.
+type LicenseTemplate struct {
+	Name    string `json:"name"`
+	Extends string `json:"extends,omitempty"`
+
+	// ValidityDays is the number of days for which licenses issued from the
+	// template are valid.
+	ValidityDays int `json:"validityDays,omitempty"`
+
+	// Payload holds the default payload of licenses issued from the
+	// template.
+	Payload map[string]interface{} `json:"payload,omitempty"`
+
+	// Pack describes the sublicenses which holders of licenses issued from
+	// the template may issue.
+	Pack map[string]*Template `json:"pack,omitempty"`
+}
+
+// Validate ensures that the template may be stored and used.
+func (t *LicenseTemplate) Validate() error {
+	if !productIDPattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid template name '%s', expected letters, digits, '-' and '_'", t.Name)
+	}
+
+	if t.Extends == t.Name {
+		return errors.New("a template cannot extend itself")
+	}
+
+	if t.ValidityDays < 0 {
+		return errors.New("expected the template's validity period to be positive")
+	}
+
+	return nil
+}
+
+// Validity returns the period for which licenses issued from the template
+// are valid, or zero if the template does not specify one.
+func (t *LicenseTemplate) Validity() time.Duration {
+	return time.Duration(t.ValidityDays) * 24 * time.Hour
+}
+
+// Apply populates license data with the template's defaults. Payload
+// entries already present in the data are retained, as is any pack, and
+// the license's LicenseTemplate is set to the template's name.
+func (t *LicenseTemplate) Apply(data *Data) {
+	if data.Meta == nil {
+		data.Meta = &Metadata{}
+	}
+
+	if data.Payload == nil {
+		data.Payload = map[string]interface{}{}
+	}
+
+	for k, v := range t.Payload {
+		if _, ok := data.Payload[k]; !ok {
+			data.Payload[k] = v
+		}
+	}
+
+	if data.Meta.Pack == nil {
+		data.Meta.Pack = t.Pack
+	}
+
+	data.Meta.LicenseTemplate = t.Name
+}
+
+// TemplateStore holds the named license templates of a product.
+type TemplateStore interface {
+	// Get retrieves the template with the given name, failing with
+	// ErrTemplateNotFound if it has not been defined.
+	Get(name string) (*LicenseTemplate, error)
+
+	// Put stores a template, replacing any existing template with the same
+	// name.
+	Put(template *LicenseTemplate) error
+
+	// Delete removes the template with the given name.
+	Delete(name string) error
+
+	// List retrieves every template, ordered by name.
+	List() ([]*LicenseTemplate, error)
+}
+
+// ResolveTemplate retrieves the named template from the store, merged with
+// every template it extends. Payload entries and pack templates defined by
+// a template override those it inherits.
+func ResolveTemplate(store TemplateStore, name string) (*LicenseTemplate, error) {
+	chain := []*LicenseTemplate{}
+	seen := map[string]bool{}
+
+	for next := name; next != ""; {
+		if seen[next] {
+			return nil, fmt.Errorf("license template '%s' extends itself", next)
+		}
+		seen[next] = true
+
+		t, err := store.Get(next)
+		if err != nil {
+			return nil, fmt.Errorf("could not load license template '%s': %s", next, err)
+		}
+
+		chain = append(chain, t)
+		next = t.Extends
+	}
+
+	resolved := &LicenseTemplate{
+		Name:    name,
+		Payload: map[string]interface{}{},
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		t := chain[i]
+		if t.ValidityDays != 0 {
+			resolved.ValidityDays = t.ValidityDays
+		}
+
+		for k, v := range t.Payload {
+			resolved.Payload[k] = v
+		}
+
+		for k, v := range t.Pack {
+			if resolved.Pack == nil {
+				resolved.Pack = map[string]*Template{}
+			}
+
+			resolved.Pack[k] = v
+		}
+	}
+
+	return resolved, nil
+}
+
+// MemoryTemplateStore is a TemplateStore which holds its templates in
+// memory. It is primarily intended for testing.
+type MemoryTemplateStore struct {
+	templates map[string]*LicenseTemplate
+	lock      sync.Mutex
+}
+
+// NewMemoryTemplateStore creates a new, empty, in-memory template store.
+func NewMemoryTemplateStore() *MemoryTemplateStore {
+	return &MemoryTemplateStore{
+		templates: map[string]*LicenseTemplate{},
+	}
+}
+
+// Get retrieves the template with the given name.
+func (s *MemoryTemplateStore) Get(name string) (*LicenseTemplate, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	t, ok := s.templates[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+
+	return t, nil
+}
+
+// Put stores a template, replacing any existing template with the same
+// name.
+func (s *MemoryTemplateStore) Put(template *LicenseTemplate) error {
+	err := template.Validate()
+	if err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.templates[template.Name] = template
+	return nil
+}
+
+// Delete removes the template with the given name.
+func (s *MemoryTemplateStore) Delete(name string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.templates[name]; !ok {
+		return ErrTemplateNotFound
+	}
+
+	delete(s.templates, name)
+	return nil
+}
+
+// List retrieves every template, ordered by name.
+func (s *MemoryTemplateStore) List() ([]*LicenseTemplate, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	templates := []*LicenseTemplate{}
+	for _, t := range s.templates {
+		templates = append(templates, t)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
+// FileTemplateStore is a TemplateStore which keeps each template in its
+// own JSON file, named after the template, within a folder.
+type FileTemplateStore struct {
+	Path string
+}
+
+// Get retrieves the template with the given name.
+func (s *FileTemplateStore) Get(name string) (*LicenseTemplate, error) {
+	if !productIDPattern.MatchString(name) {
+		return nil, ErrTemplateNotFound
+	}
+
+	data, err := ioutil.ReadFile(s.templatePath(name))
+	if os.IsNotExist(err) {
+		return nil, ErrTemplateNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var t LicenseTemplate
+	err = json.Unmarshal(data, &t)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.Name != name {
+		return nil, fmt.Errorf("the template file for '%s' defines the template '%s'", name, t.Name)
+	}
+
+	return &t, nil
+}
+
+// Put stores a template, replacing any existing template with the same
+// name.
+func (s *FileTemplateStore) Put(template *LicenseTemplate) error {
+	err := template.Validate()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(template, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(s.Path, os.ModePerm|os.ModeDir)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(s.templatePath(template.Name), data, 0644)
+}
+
+// Delete removes the template with the given name.
+func (s *FileTemplateStore) Delete(name string) error {
+	if !productIDPattern.MatchString(name) {
+		return ErrTemplateNotFound
+	}
+
+	err := os.Remove(s.templatePath(name))
+	if os.IsNotExist(err) {
+		return ErrTemplateNotFound
+	}
+
+	return err
+}
+
+// List retrieves every template, ordered by name. Files which do not
+// contain a valid template are skipped.
+func (s *FileTemplateStore) List() ([]*LicenseTemplate, error) {
+	files, err := filepath.Glob(filepath.Join(s.Path, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	templates := []*LicenseTemplate{}
+	for _, file := range files {
+		t, err := s.Get(strings.TrimSuffix(filepath.Base(file), ".json"))
+		if err != nil {
+			continue
+		}
+
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+func (s *FileTemplateStore) templatePath(name string) string {
+	return filepath.Join(s.Path, fmt.Sprintf("%s.json", name))
+}
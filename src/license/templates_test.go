@@ -0,0 +1,110 @@
+package license
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestFileTemplateStore(t *testing.T) {
+	tempDir := os.TempDir()
+	testPath, err := ioutil.TempDir(tempDir, "lithium")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(testPath)
+
+	store := &FileTemplateStore{Path: testPath}
+
+	templates := []*LicenseTemplate{
+		{
+			Name:         "pro",
+			ValidityDays: 30,
+			Payload:      map[string]interface{}{"tier": "pro", "seats": 5.0},
+			Pack: map[string]*Template{
+				"trial": {Count: 10, Payload: map[string]interface{}{"tier": "trial"}},
+			},
+		},
+		{
+			Name:         "enterprise",
+			Extends:      "pro",
+			ValidityDays: 365,
+			Payload:      map[string]interface{}{"seats": 50.0},
+		},
+		{Name: "loop-a", Extends: "loop-b"},
+		{Name: "loop-b", Extends: "loop-a"},
+	}
+
+	for _, tmpl := range templates {
+		err := store.Put(tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.Put(&LicenseTemplate{Name: "../escape"}); err == nil {
+		t.Error("expected a template with an invalid name to be rejected")
+	}
+
+	list, err := store.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 4 || list[0].Name != "enterprise" || list[3].Name != "pro" {
+		t.Errorf("expected the templates to be listed by name, got %d templates", len(list))
+	}
+
+	resolved, err := ResolveTemplate(store, "enterprise")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved.Payload["tier"] != "pro" || resolved.Payload["seats"] != 50.0 {
+		t.Errorf("expected the payload to be inherited and overridden, got %#v", resolved.Payload)
+	}
+
+	if resolved.Validity() != 365*24*time.Hour {
+		t.Errorf("expected the validity period to be overridden, got %s", resolved.Validity())
+	}
+
+	if _, ok := resolved.Pack["trial"]; !ok {
+		t.Error("expected the pack to be inherited")
+	}
+
+	if _, err := ResolveTemplate(store, "loop-a"); err == nil {
+		t.Error("expected a template which extends itself to be rejected")
+	}
+
+	if _, err := ResolveTemplate(store, "missing"); err == nil {
+		t.Error("expected an unknown template to be rejected")
+	}
+
+	data := &Data{
+		Meta:    &Metadata{ID: "test", Template: "reseller"},
+		Payload: map[string]interface{}{"seats": 10.0},
+	}
+	resolved.Apply(data)
+
+	if data.Payload["seats"] != 10.0 || data.Payload["tier"] != "pro" {
+		t.Errorf("expected the template to provide payload defaults, got %#v", data.Payload)
+	}
+
+	if data.Meta.LicenseTemplate != "enterprise" || data.Meta.Pack["trial"] == nil {
+		t.Errorf("expected the license to be marked with the template and its pack, got %#v", data.Meta)
+	}
+
+	if data.Meta.Template != "reseller" {
+		t.Errorf("expected the license's pack template to be retained, got '%s'", data.Meta.Template)
+	}
+
+	err = store.Delete("loop-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get("loop-a"); err != ErrTemplateNotFound {
+		t.Errorf("expected the deleted template to be missing, got %v", err)
+	}
+}
@@ -5,6 +5,14 @@ import (
 	"time"
 )
 
+// ErrNotActivated is returned by Data.IsValid when the license's
+// activation time has not yet been reached.
+var ErrNotActivated = errors.New("license has not yet activated due to time constraint")
+
+// ErrExpired is returned by Data.IsValid when the license's expiry time
+// has passed.
+var ErrExpired = errors.New("license has expired due to time constraint")
+
 // IsValid determines whether a license object is valid for use.
 // It does so by checking that the metadata is present, that it
 // is within the validity period and that there is a defined payload.
@@ -16,11 +24,11 @@ func (l *Data) IsValid() (bool, error) {
 	now := time.Now()
 
 	if now.Before(l.Meta.ActivatesOn) {
-		return false, errors.New("license has not yet activated due to time constraint")
+		return false, ErrNotActivated
 	}
 
 	if now.After(l.Meta.ExpiresOn) {
-		return false, errors.New("license has expired due to time constraint")
+		return false, ErrExpired
 	}
 
 	if l.Payload == nil {
@@ -3,9 +3,9 @@ package main
 import (
 	"log"
 	"os"
-	"path/filepath"
 
 	"github.com/SierraSoftworks/Lithium/src/commands"
+	"github.com/SierraSoftworks/Lithium/src/commands/shared"
 	"github.com/codegangsta/cli"
 )
 
@@ -17,19 +17,15 @@ func main() {
 	app.Usage = "Manage your Lithium licenses"
 	app.Commands = commands.Commands
 
-	cwd, err := os.Getwd()
+	_, _, err := shared.LoadConfig()
 	if err != nil {
-		log.Printf("error determining working directory: %s", err)
+		log.Printf("error loading configuration: %s", err)
 		os.Exit(1)
 	}
 
 	app.Flags = []cli.Flag{
-		cli.StringFlag{
-			Name:   "licensePath",
-			Usage:  "the `path` under which licenses are stored",
-			EnvVar: "LITHIUM_LICENSE_PATH",
-			Value:  filepath.Join(cwd, "./.lithium"),
-		},
+		shared.LicensePathFlag(),
+		shared.OutputFlag(),
 	}
 
 	app.Author = "Benjamin Pannell"
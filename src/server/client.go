@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// Client requests licenses from a license server on behalf of a machine.
+type Client struct {
+	// URL is the base URL of the server, for example https://licensing.example.com.
+	URL string
+
+	// HTTPClient is used to make requests, defaulting to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// RequestLicense requests a license for the machine and product of the
+// given KeyManager, issued from the named template, and returns the
+// license container issued by the server. The container should be
+// installed using KeyManager.InstallLicenseResponse.
+func (c *Client) RequestLicense(km *license.KeyManager, template string) ([]byte, error) {
+	req, err := km.CreateLicenseRequest(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.post(LicensesPath, req)
+}
+
+// Checkout checks out a floating license for the machine and product of
+// the given KeyManager, issued from the named template, and returns the
+// license container issued for the seat. The seat must be renewed, using
+// the container's license ID, before the license expires.
+func (c *Client) Checkout(km *license.KeyManager, template string) ([]byte, error) {
+	req, err := km.CreateLicenseRequest(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.post(SeatsPath, req)
+}
+
+// Renew extends the lease of the floating license with the given ID,
+// returning a new license container which expires at the end of the
+// extended lease.
+func (c *Client) Renew(km *license.KeyManager, licenseID string) ([]byte, error) {
+	req, err := km.CreateSeatRequest(licenseID)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.post(SeatPath(licenseID, "renew"), req)
+}
+
+// Release returns the floating license with the given ID to its pool.
+func (c *Client) Release(km *license.KeyManager, licenseID string) error {
+	req, err := km.CreateSeatRequest(licenseID)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.post(SeatPath(licenseID, "release"), req)
+	return err
+}
+
+func (c *Client) post(path string, body []byte) ([]byte, error) {
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Post(strings.TrimSuffix(c.URL, "/")+path, LicenseContentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, MaxRequestSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		var e ErrorResponse
+		if json.Unmarshal(data, &e) == nil && e.Error != "" {
+			return nil, &ResponseError{StatusCode: res.StatusCode, Message: e.Error}
+		}
+
+		return nil, &ResponseError{StatusCode: res.StatusCode, Message: http.StatusText(res.StatusCode)}
+	}
+
+	return data, nil
+}
+
+// ResponseError is returned by a Client when the server refuses a request.
+type ResponseError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("the license server responded with %d: %s", e.StatusCode, e.Message)
+}
@@ -0,0 +1,331 @@
+package server
+
+import (
+	"crypto/x509"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// DefaultRenewBefore is how long before its license expires that a
+// LeaseManager renews its seat, unless configured otherwise.
+const DefaultRenewBefore = 30 * time.Second
+
+// DefaultMinBackoff and DefaultMaxBackoff bound the delay between failed
+// attempts to renew a seat, unless configured otherwise.
+const (
+	DefaultMinBackoff = time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// ErrLeaseStarted is returned when a LeaseManager which has already been
+// started is started again.
+var ErrLeaseStarted = errors.New("the lease manager has already been started")
+
+// LeaseManager holds a floating license checked out from a license server
+// on behalf of a machine, renewing it before it expires for as long as the
+// application runs and releasing its seat when the application stops.
+//
+// Each license issued by the server is validated against the product's
+// root certificate and decrypted with the machine's private key, but is
+// not installed, since it is only valid for the seat's short lease.
+type LeaseManager struct {
+	// Client is used to make requests to the license server.
+	Client *Client
+
+	// Machine is the KeyManager of the machine which holds the seat, and
+	// whose product it is checked out for.
+	Machine *license.KeyManager
+
+	// Root is the product's root certificate, against which each license
+	// is validated.
+	Root *x509.Certificate
+
+	// Template is the name of the license template from which the license
+	// should be issued, which may be empty to use the server's default.
+	Template string
+
+	// RenewBefore is how long before the license expires that it should be
+	// renewed, defaulting to DefaultRenewBefore. Licenses whose lifetime is
+	// shorter than twice this are renewed half way through their lifetime.
+	RenewBefore time.Duration
+
+	// MinBackoff and MaxBackoff bound the jittered, exponentially growing
+	// delay between failed attempts to renew the license, defaulting to
+	// DefaultMinBackoff and DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// GracePeriod is how long after the license expires that renewals are
+	// retried, when the server could not be reached, before it is given up.
+	GracePeriod time.Duration
+
+	// OnAcquired, if provided, is called with the license issued when the
+	// seat is checked out.
+	OnAcquired func(data *license.Data)
+
+	// OnRenewed, if provided, is called with each renewed license.
+	OnRenewed func(data *license.Data)
+
+	// OnLost, if provided, is called when the server refuses to renew the
+	// seat, typically because it has expired and been returned to the pool.
+	OnLost func(err error)
+
+	// OnGraceExpired, if provided, is called with the last license issued
+	// once renewals have failed until the end of its grace period.
+	OnGraceExpired func(data *license.Data)
+
+	data    *license.Data
+	held    bool
+	started bool
+	stop    chan struct{}
+	done    chan struct{}
+	lock    sync.Mutex
+}
+
+// Start checks out a seat and begins renewing it in the background,
+// returning the license issued for it.
+func (m *LeaseManager) Start() (*license.Data, error) {
+	data, err := m.checkout()
+	if err != nil {
+		return nil, err
+	}
+
+	if m.OnAcquired != nil {
+		m.OnAcquired(data)
+	}
+
+	go m.run(data)
+	return data, nil
+}
+
+func (m *LeaseManager) checkout() (*license.Data, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.started {
+		return nil, ErrLeaseStarted
+	}
+
+	response, err := m.Client.Checkout(m.Machine, m.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := m.decrypt(response)
+	if err != nil {
+		return nil, err
+	}
+
+	m.data = data
+	m.held = true
+	m.started = true
+	m.stop = make(chan struct{})
+	if m.done == nil {
+		m.done = make(chan struct{})
+	}
+
+	return data, nil
+}
+
+// License retrieves the license currently held, or nil if the seat is not
+// held.
+func (m *LeaseManager) License() *license.Data {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.held {
+		return nil
+	}
+
+	return m.data
+}
+
+// Done returns a channel which is closed once the manager stops renewing
+// its seat, whether because it was stopped or because the seat was lost.
+func (m *LeaseManager) Done() <-chan struct{} {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.done == nil {
+		m.done = make(chan struct{})
+	}
+
+	return m.done
+}
+
+// Stop stops renewing the seat and, if it is still held, releases it back
+// to the pool.
+func (m *LeaseManager) Stop() error {
+	m.lock.Lock()
+	if !m.started {
+		m.lock.Unlock()
+		return nil
+	}
+
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+
+	done := m.done
+	m.lock.Unlock()
+
+	<-done
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if !m.held {
+		return nil
+	}
+
+	m.held = false
+	return m.Client.Release(m.Machine, m.data.Meta.ID)
+}
+
+// run renews the seat until the manager is stopped or the seat is lost,
+// closing done before the callbacks reporting a lost seat are called so
+// that they may stop the manager.
+func (m *LeaseManager) run(data *license.Data) {
+	failures := 0
+	for {
+		var wait time.Duration
+		if failures == 0 {
+			wait = time.Until(data.Meta.ExpiresOn.Add(-m.renewBefore(data)))
+		} else {
+			wait = m.backoff(failures)
+			if deadline := time.Until(data.Meta.ExpiresOn.Add(m.GracePeriod)); wait > deadline {
+				wait = deadline
+			}
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-m.stop:
+			timer.Stop()
+			close(m.done)
+			return
+		case <-timer.C:
+		}
+
+		renewed, err := m.renew(data.Meta.ID)
+		if err == nil {
+			data = renewed
+			failures = 0
+
+			m.lock.Lock()
+			m.data = renewed
+			m.lock.Unlock()
+
+			if m.OnRenewed != nil {
+				m.OnRenewed(renewed)
+			}
+
+			continue
+		}
+
+		if e, ok := err.(*ResponseError); ok && e.StatusCode >= 400 && e.StatusCode < 500 {
+			m.lose()
+			if m.OnLost != nil {
+				m.OnLost(err)
+			}
+
+			return
+		}
+
+		if !time.Now().Before(data.Meta.ExpiresOn.Add(m.GracePeriod)) {
+			m.lose()
+			if m.OnGraceExpired != nil {
+				m.OnGraceExpired(data)
+			}
+
+			return
+		}
+
+		failures++
+	}
+}
+
+func (m *LeaseManager) renew(licenseID string) (*license.Data, error) {
+	response, err := m.Client.Renew(m.Machine, licenseID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := m.decrypt(response)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.Meta.ID != licenseID {
+		return nil, errors.New("the license server renewed a different license")
+	}
+
+	return data, nil
+}
+
+func (m *LeaseManager) decrypt(response []byte) (*license.Data, error) {
+	c, err := license.ParseContainer(response)
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := m.Machine.GetPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.LicenseFor(privKey, m.Root, m.Machine.Product)
+}
+
+func (m *LeaseManager) lose() {
+	m.lock.Lock()
+	m.held = false
+	m.lock.Unlock()
+
+	close(m.done)
+}
+
+func (m *LeaseManager) renewBefore(data *license.Data) time.Duration {
+	renewBefore := m.RenewBefore
+	if renewBefore <= 0 {
+		renewBefore = DefaultRenewBefore
+	}
+
+	if lifetime := data.Meta.ExpiresOn.Sub(data.Meta.ActivatesOn); renewBefore > lifetime/2 {
+		return lifetime / 2
+	}
+
+	return renewBefore
+}
+
+// backoff returns the delay before the next attempt to renew the license
+// after the given number of consecutive failures, which doubles with each
+// failure and is jittered so that clients disconnected together do not
+// retry together.
+func (m *LeaseManager) backoff(failures int) time.Duration {
+	min, max := m.MinBackoff, m.MaxBackoff
+	if min <= 0 {
+		min = DefaultMinBackoff
+	}
+
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	backoff := min
+	for i := 1; i < failures && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	if backoff > max {
+		backoff = max
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+}
@@ -0,0 +1,186 @@
+package server_test
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/server"
+	"github.com/SierraSoftworks/Lithium/src/server/servertest"
+)
+
+// unreachable is an http.RoundTripper which fails every request once it
+// has been disconnected.
+type unreachable struct {
+	disconnected int32
+	next         http.RoundTripper
+}
+
+func (u *unreachable) RoundTrip(req *http.Request) (*http.Response, error) {
+	if atomic.LoadInt32(&u.disconnected) != 0 {
+		return nil, errors.New("the server is unreachable")
+	}
+
+	return u.next.RoundTrip(req)
+}
+
+func newLeaseManager(h *servertest.Harness) *server.LeaseManager {
+	h.Issuer.Seats = license.NewSeatPool(1, 2*time.Second)
+
+	return &server.LeaseManager{
+		Client:      h.Client(),
+		Machine:     h.Machine("machine"),
+		Root:        h.Root,
+		RenewBefore: 1500 * time.Millisecond,
+		MinBackoff:  50 * time.Millisecond,
+		MaxBackoff:  200 * time.Millisecond,
+	}
+}
+
+func TestLeaseManagerRenews(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	m := newLeaseManager(h)
+
+	acquired := make(chan *license.Data, 1)
+	renewed := make(chan *license.Data, 10)
+	m.OnAcquired = func(data *license.Data) { acquired <- data }
+	m.OnRenewed = func(data *license.Data) { renewed <- data }
+
+	data, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := <-acquired; got.Meta.ID != data.Meta.ID {
+		t.Errorf("expected OnAcquired to receive license %s, got %s", data.Meta.ID, got.Meta.ID)
+	}
+
+	if _, err := m.Start(); err != server.ErrLeaseStarted {
+		t.Errorf("expected a started lease manager not to be started again, got %v", err)
+	}
+
+	select {
+	case got := <-renewed:
+		if got.Meta.ID != data.Meta.ID || !got.Meta.ExpiresOn.After(data.Meta.ExpiresOn) {
+			t.Errorf("expected license %s to be extended, got %s expiring %s", data.Meta.ID, got.Meta.ID, got.Meta.ExpiresOn)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the license to be renewed before it expired")
+	}
+
+	if m.License() == nil {
+		t.Error("expected the lease manager to hold a license")
+	}
+
+	err = m.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.License() != nil {
+		t.Error("expected the lease manager not to hold a license once stopped")
+	}
+
+	if available := h.Issuer.Seats.Available(); available != 1 {
+		t.Errorf("expected the seat to be released, got %d available", available)
+	}
+}
+
+func TestLeaseManagerLost(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	m := newLeaseManager(h)
+
+	lost := make(chan error, 1)
+	m.OnLost = func(err error) {
+		lost <- err
+		m.Stop()
+	}
+
+	data, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seats := h.Issuer.Seats.Seats()
+	if len(seats) != 1 {
+		t.Fatalf("expected a seat to be checked out, got %d", len(seats))
+	}
+
+	err = h.Issuer.Seats.Release(data.Meta.ID, seats[0].Client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-lost:
+		if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusConflict {
+			t.Errorf("expected the renewal to be refused, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the seat to be lost")
+	}
+
+	<-m.Done()
+	if m.License() != nil {
+		t.Error("expected the lease manager not to hold a license once it was lost")
+	}
+}
+
+func TestLeaseManagerGraceExpired(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	m := newLeaseManager(h)
+	m.GracePeriod = 500 * time.Millisecond
+
+	transport := &unreachable{next: m.Client.HTTPClient.Transport}
+	if transport.next == nil {
+		transport.next = http.DefaultTransport
+	}
+	m.Client.HTTPClient = &http.Client{Transport: transport}
+
+	expired := make(chan *license.Data, 1)
+	m.OnRenewed = func(data *license.Data) { t.Error("expected the license not to be renewed") }
+	m.OnGraceExpired = func(data *license.Data) { expired <- data }
+
+	data, err := m.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&transport.disconnected, 1)
+
+	select {
+	case got := <-expired:
+		if got.Meta.ID != data.Meta.ID {
+			t.Errorf("expected OnGraceExpired to receive license %s, got %s", data.Meta.ID, got.Meta.ID)
+		}
+
+		if deadline := data.Meta.ExpiresOn.Add(m.GracePeriod); time.Now().Before(deadline) {
+			t.Errorf("expected the grace period to expire no earlier than %s", deadline)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the grace period to expire")
+	}
+
+	<-m.Done()
+	if err := m.Stop(); err != nil {
+		t.Errorf("expected a lease manager whose grace period expired to stop without releasing its seat, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// DefaultValidity is the period for which licenses are issued by a
+// TemplatePolicy when neither it nor the requested template specify one.
+const DefaultValidity = 365 * 24 * time.Hour
+
+// Policy decides whether license requests should be fulfilled.
+type Policy interface {
+	// License builds the license data with which a request should be
+	// fulfilled, or returns a *DeniedError if it should not be.
+	License(req *license.LicenseRequest) (*license.Data, error)
+}
+
+// DeniedError is returned by a Policy which refuses a license request.
+type DeniedError struct {
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("license request denied: %s", e.Reason)
+}
+
+func denied(format string, args ...interface{}) error {
+	return &DeniedError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// TemplatePolicy issues licenses from a product's named license templates.
+type TemplatePolicy struct {
+	// Templates holds the product's license templates.
+	Templates license.TemplateStore
+
+	// Allowed lists the templates which machines may request. If it is
+	// empty, only DefaultTemplate may be requested, since requests are not
+	// authenticated and templates may grant their holders packs of
+	// sublicenses.
+	Allowed []string
+
+	// DefaultTemplate is used for requests which do not name a template.
+	// If it is empty, such requests are issued licenses without a
+	// template, unless Allowed is provided and does not include "".
+	DefaultTemplate string
+
+	// Validity is the period for which licenses are valid if their
+	// template does not specify one. It defaults to DefaultValidity.
+	Validity time.Duration
+
+	// MaxClockSkew, if provided, is the greatest difference between the
+	// server's clock and the requesting machine's which is accepted.
+	MaxClockSkew time.Duration
+}
+
+// License builds the license data for a request from its template.
+func (p *TemplatePolicy) License(req *license.LicenseRequest) (*license.Data, error) {
+	now := time.Now()
+
+	if p.MaxClockSkew > 0 && !req.ClientTime.IsZero() {
+		skew := req.ClientTime.Sub(now)
+		if skew < 0 {
+			skew = -skew
+		}
+
+		if skew > p.MaxClockSkew {
+			return nil, denied("the machine's clock differs from the server's by %s", skew)
+		}
+	}
+
+	name := req.Template
+	if name == "" {
+		name = p.DefaultTemplate
+	}
+
+	if !p.allows(name) {
+		if name == "" {
+			return nil, denied("a license template must be requested")
+		}
+
+		return nil, denied("the license template '%s' may not be requested", name)
+	}
+
+	id, err := license.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	validity := p.Validity
+	if validity <= 0 {
+		validity = DefaultValidity
+	}
+
+	data := &license.Data{
+		Meta: &license.Metadata{
+			ID:          id,
+			ActivatesOn: now,
+			ExpiresOn:   now.Add(validity),
+		},
+		Payload: map[string]interface{}{},
+	}
+
+	if name == "" {
+		return data, nil
+	}
+
+	if p.Templates == nil {
+		return nil, denied("the license template '%s' does not exist", name)
+	}
+
+	if _, err := p.Templates.Get(name); err == license.ErrTemplateNotFound {
+		return nil, denied("the license template '%s' does not exist", name)
+	}
+
+	t, err := license.ResolveTemplate(p.Templates, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.ValidityDays > 0 {
+		data.Meta.ExpiresOn = now.Add(t.Validity())
+	}
+
+	t.Apply(data)
+	return data, nil
+}
+
+func (p *TemplatePolicy) allows(name string) bool {
+	if len(p.Allowed) == 0 {
+		return name == p.DefaultTemplate
+	}
+
+	for _, allowed := range p.Allowed {
+		if allowed == name {
+			return true
+		}
+	}
+
+	return false
+}
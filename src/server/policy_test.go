@@ -0,0 +1,63 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+func TestTemplatePolicy(t *testing.T) {
+	store := license.NewMemoryTemplateStore()
+	store.Put(&license.LicenseTemplate{Name: "base", ValidityDays: 10, Payload: map[string]interface{}{"tier": "base"}})
+	store.Put(&license.LicenseTemplate{Name: "trial", Extends: "base"})
+
+	policy := &TemplatePolicy{
+		Templates:       store,
+		DefaultTemplate: "trial",
+		MaxClockSkew:    5 * time.Minute,
+	}
+
+	data, err := policy.License(&license.LicenseRequest{Product: "testing", ClientTime: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.LicenseTemplate != "trial" || data.Payload["tier"] != "base" {
+		t.Errorf("expected the default template to be applied, got %s with %v", data.Meta.LicenseTemplate, data.Payload)
+	}
+
+	if validity := data.Meta.ExpiresOn.Sub(data.Meta.ActivatesOn); validity != 10*24*time.Hour {
+		t.Errorf("expected the inherited validity period to be used, got %s", validity)
+	}
+
+	_, err = policy.License(&license.LicenseRequest{Product: "testing", ClientTime: time.Now().Add(-time.Hour)})
+	if _, ok := err.(*DeniedError); !ok {
+		t.Errorf("expected a request from a machine with a skewed clock to be denied, got %v", err)
+	}
+
+	_, err = policy.License(&license.LicenseRequest{Product: "testing", Template: "base"})
+	if _, ok := err.(*DeniedError); !ok {
+		t.Errorf("expected a request for a template other than the default to be denied without an allow list, got %v", err)
+	}
+
+	policy.Allowed = []string{"trial", "missing"}
+	_, err = policy.License(&license.LicenseRequest{Product: "testing", Template: "missing"})
+	if _, ok := err.(*DeniedError); !ok {
+		t.Errorf("expected a request for an unknown template to be denied, got %v", err)
+	}
+
+	data, err = (&TemplatePolicy{}).License(&license.LicenseRequest{Product: "testing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.LicenseTemplate != "" || data.Meta.ExpiresOn.Sub(data.Meta.ActivatesOn) != DefaultValidity {
+		t.Errorf("expected a license without a template to use the default validity period")
+	}
+
+	_, err = (&TemplatePolicy{Templates: store}).License(&license.LicenseRequest{Product: "testing", Template: "trial"})
+	if _, ok := err.(*DeniedError); !ok {
+		t.Errorf("expected a policy without an allow list or default template to deny requests naming a template, got %v", err)
+	}
+}
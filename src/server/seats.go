@@ -0,0 +1,249 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// SeatsPath is the path to which requests to check out floating licenses
+// are posted. A seat is renewed or released by posting a request to its
+// renew or release path, beneath SeatsPath and the seat's license ID.
+const SeatsPath = "/v1/seats"
+
+// SeatPath returns the path to which a request to renew or release the
+// seat with the given license ID is posted, where action is either
+// "renew" or "release".
+func SeatPath(licenseID, action string) string {
+	return fmt.Sprintf("%s/%s/%s", SeatsPath, licenseID, action)
+}
+
+// ErrNoSeatPool is returned when a floating license is requested for a
+// product whose issuer does not have a seat pool.
+var ErrNoSeatPool = errors.New("floating licenses are not issued for this product")
+
+// Checkout checks out a seat from the pool of the request's product for
+// the requesting machine, and issues a license which expires along with
+// the seat's lease.
+func (s *Server) Checkout(req *license.LicenseRequest) (*license.Container, *license.Seat, error) {
+	issuer, err := s.seatIssuer(req.Product)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, err := issuer.Policy.License(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seat, err := issuer.Seats.Checkout(req.Fingerprint, data.Meta.LicenseTemplate)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := issuer.seatLicense(req, data, seat)
+	if err != nil {
+		issuer.Seats.Release(seat.LicenseID, seat.Client)
+		return nil, nil, err
+	}
+
+	return c, seat, nil
+}
+
+// Renew extends the lease of a seat held by the requesting machine and
+// issues a license which expires along with it. The request must have been
+// created for the seat's license ID, as with KeyManager.CreateSeatRequest,
+// within the issuer's MaxSkew of the server's clock and may only be used
+// once. Renewed licenses are issued from the template the seat was checked
+// out with, and if the issuer's policy no longer permits that the seat is
+// released.
+func (s *Server) Renew(req *license.LicenseRequest, licenseID string) (*license.Container, *license.Seat, error) {
+	issuer, err := s.seatIssuer(req.Product)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.checkSeatRequest(issuer, req, licenseID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seat, err := issuer.Seats.Renew(licenseID, req.Fingerprint)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	renewal := *req
+	renewal.Template = seat.Template
+
+	data, err := issuer.Policy.License(&renewal)
+	if err == nil {
+		var c *license.Container
+		c, err = issuer.seatLicense(&renewal, data, seat)
+		if err == nil {
+			return c, seat, nil
+		}
+	}
+
+	issuer.Seats.Release(seat.LicenseID, seat.Client)
+	return nil, nil, err
+}
+
+// Release returns a seat held by the requesting machine to its pool. The
+// request is checked as with Renew, so that a captured request cannot be
+// replayed to release a seat which has since been renewed.
+func (s *Server) Release(req *license.LicenseRequest, licenseID string) error {
+	issuer, err := s.seatIssuer(req.Product)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkSeatRequest(issuer, req, licenseID)
+	if err != nil {
+		return err
+	}
+
+	return issuer.Seats.Release(licenseID, req.Fingerprint)
+}
+
+// checkSeatRequest ensures that a request to renew or release a seat was
+// created for that seat, recently, and has not been seen before. Nonces
+// are remembered for twice the issuer's MaxSkew, after which requests
+// carrying them are refused as stale anyway.
+func (s *Server) checkSeatRequest(issuer *Issuer, req *license.LicenseRequest, licenseID string) error {
+	if req.LicenseID != licenseID {
+		return denied("the request was not created for seat %s", licenseID)
+	}
+
+	if req.ClientTime.IsZero() {
+		return denied("the request does not record when it was created")
+	}
+
+	maxSkew := issuer.maxSkew()
+	skew := req.ClientTime.Sub(time.Now())
+	if skew < 0 {
+		skew = -skew
+	}
+
+	if skew > maxSkew {
+		return denied("the request was created %s from the server's clock, more than the %s allowed", skew, maxSkew)
+	}
+
+	if req.Nonce == "" {
+		return denied("the request does not carry a nonce")
+	}
+
+	s.nonceLock.Lock()
+	defer s.nonceLock.Unlock()
+
+	now := time.Now()
+	for nonce, expires := range s.nonces {
+		if now.After(expires) {
+			delete(s.nonces, nonce)
+		}
+	}
+
+	if _, seen := s.nonces[req.Nonce]; seen {
+		return denied("the request has already been made")
+	}
+
+	s.nonces[req.Nonce] = now.Add(2 * maxSkew)
+	return nil
+}
+
+func (s *Server) seatIssuer(productID string) (*Issuer, error) {
+	issuer, ok := s.Issuer(productID)
+	if !ok {
+		return nil, ErrUnknownProduct
+	}
+
+	if issuer.Seats == nil {
+		return nil, ErrNoSeatPool
+	}
+
+	return issuer, nil
+}
+
+// seatLicense issues the license for a seat, which carries the seat's
+// license ID and is valid for its lease. Machines whose clocks are ahead
+// of the server's are issued licenses which expire correspondingly later,
+// by no more than the issuer's MaxSkew, so that they do not expire early
+// on those machines.
+func (i *Issuer) seatLicense(req *license.LicenseRequest, data *license.Data, seat *license.Seat) (*license.Container, error) {
+	data.Meta.ID = seat.LicenseID
+	data.Meta.ActivatesOn = seat.CheckedOutOn
+	data.Meta.ExpiresOn = seat.ExpiresOn
+
+	if skew := req.ClientTime.Sub(time.Now()); !req.ClientTime.IsZero() && skew > 0 {
+		if maxSkew := i.maxSkew(); skew > maxSkew {
+			skew = maxSkew
+		}
+
+		data.Meta.ExpiresOn = data.Meta.ExpiresOn.Add(skew)
+	}
+
+	return i.CertManager.FulfilLicenseRequest(req, data, i.Key, i.algorithm())
+}
+
+func (i *Issuer) maxSkew() time.Duration {
+	if i.MaxSkew > 0 {
+		return i.MaxSkew
+	}
+
+	return i.Seats.Lease
+}
+
+func (s *Server) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	c, seat, err := s.Checkout(req)
+	if err != nil {
+		s.writeError(w, r, errorStatus(err), err)
+		return
+	}
+
+	s.logf("%s checked out seat %s for %s to %s until %s", r.RemoteAddr, seat.LicenseID, req.Product, req.Fingerprint, seat.ExpiresOn.Format(time.RFC3339))
+	s.writeContainer(w, r, c)
+}
+
+func (s *Server) handleSeat(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, SeatsPath+"/"), "/")
+	if len(parts) != 2 || parts[0] == "" || (parts[1] != "renew" && parts[1] != "release") {
+		s.writeError(w, r, http.StatusNotFound, fmt.Errorf("no resource exists at %s", r.URL.Path))
+		return
+	}
+
+	req, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	licenseID := parts[0]
+	if parts[1] == "release" {
+		err := s.Release(req, licenseID)
+		if err != nil {
+			s.writeError(w, r, errorStatus(err), err)
+			return
+		}
+
+		s.logf("%s released seat %s for %s", r.RemoteAddr, licenseID, req.Product)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c, seat, err := s.Renew(req, licenseID)
+	if err != nil {
+		s.writeError(w, r, errorStatus(err), err)
+		return
+	}
+
+	s.logf("%s renewed seat %s for %s until %s", r.RemoteAddr, seat.LicenseID, req.Product, seat.ExpiresOn.Format(time.RFC3339))
+	s.writeContainer(w, r, c)
+}
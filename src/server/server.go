@@ -0,0 +1,266 @@
+// Package server implements an HTTP license server, which issues licenses
+// to machines in response to the license requests they sign with their
+// keys, subject to an issuance policy.
+package server
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+)
+
+// LicensesPath is the path to which license requests are posted.
+const LicensesPath = "/v1/licenses"
+
+// LicenseContentType is the content type of the license requests accepted
+// by the server and of the license containers it returns.
+const LicenseContentType = "application/x-pem-file"
+
+// MaxRequestSize is the largest request body the server will accept.
+const MaxRequestSize = 64 * 1024
+
+// ErrUnknownProduct is returned when a license is requested for a product
+// which the server does not issue licenses for.
+var ErrUnknownProduct = errors.New("licenses are not issued for this product")
+
+// ErrSeatsRequired is returned when a license is requested for a product
+// whose issuer has a seat pool, since its licenses are only issued as
+// floating licenses checked out through SeatsPath.
+var ErrSeatsRequired = errors.New("licenses for this product must be checked out as floating seats")
+
+// Issuer holds everything the server needs to issue licenses for a single
+// product.
+type Issuer struct {
+	// CertManager holds the certificate chain with which licenses are
+	// signed, and the ledger in which they are recorded.
+	CertManager *license.CertManager
+
+	// Key is the private key matching the final certificate of the chain.
+	Key crypto.Signer
+
+	// Algorithm is the hash algorithm used to sign licenses, one of sha1,
+	// sha256 or sha512. It defaults to sha256.
+	Algorithm string
+
+	// Policy decides which requests are fulfilled and describes the
+	// licenses issued for them.
+	Policy Policy
+
+	// Seats, if provided, is the pool from which floating licenses are
+	// checked out.
+	Seats *license.SeatPool
+
+	// MaxSkew is the furthest ahead of the server's clock that a machine's
+	// clock may be for the floating licenses issued to it to be extended to
+	// match. It defaults to the lease of the seat pool, and regardless of
+	// the issuer's policy no license outlives its seat by more than this.
+	// Requests to renew or release a seat must also have been created
+	// within this of the server's clock.
+	MaxSkew time.Duration
+}
+
+func (i *Issuer) algorithm() string {
+	if i.Algorithm == "" {
+		return "sha256"
+	}
+
+	return i.Algorithm
+}
+
+// Server is an http.Handler which issues licenses for the products of its
+// issuers. License requests, as produced by KeyManager.CreateLicenseRequest,
+// are posted to LicensesPath and answered with a license container which
+// may be installed using KeyManager.InstallLicenseResponse. Floating
+// licenses are checked out, renewed and released through SeatsPath.
+type Server struct {
+	// Log, if provided, receives a line describing each request.
+	Log *log.Logger
+
+	issuers map[string]*Issuer
+	mux     *http.ServeMux
+	lock    sync.RWMutex
+
+	nonces    map[string]time.Time
+	nonceLock sync.Mutex
+}
+
+// New creates a server which does not yet issue licenses for any product.
+func New() *Server {
+	s := &Server{
+		issuers: map[string]*Issuer{},
+		mux:     http.NewServeMux(),
+		nonces:  map[string]time.Time{},
+	}
+
+	s.mux.HandleFunc(LicensesPath, s.handleLicenses)
+	s.mux.HandleFunc(SeatsPath, s.handleCheckout)
+	s.mux.HandleFunc(SeatsPath+"/", s.handleSeat)
+	return s
+}
+
+// AddIssuer registers the issuer of a product's licenses, replacing any
+// existing issuer for that product.
+func (s *Server) AddIssuer(issuer *Issuer) error {
+	if issuer == nil || issuer.CertManager == nil || issuer.CertManager.Product == nil {
+		return errors.New("expected the issuer's certificate manager and product to be provided")
+	}
+
+	if issuer.Key == nil {
+		return errors.New("expected the issuer's signing key to be provided")
+	}
+
+	if issuer.Policy == nil {
+		return errors.New("expected the issuer's policy to be provided")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.issuers[issuer.CertManager.Product.ID] = issuer
+	return nil
+}
+
+// Issuer retrieves the issuer registered for a product.
+func (s *Server) Issuer(productID string) (*Issuer, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	issuer, ok := s.issuers[productID]
+	return issuer, ok
+}
+
+// Issue fulfils a license request using the issuer of its product, once
+// the issuer's policy has approved it. Products whose issuer has a seat
+// pool are only issued floating licenses, through Checkout.
+func (s *Server) Issue(req *license.LicenseRequest) (*license.Container, *license.Data, error) {
+	issuer, ok := s.Issuer(req.Product)
+	if !ok {
+		return nil, nil, ErrUnknownProduct
+	}
+
+	if issuer.Seats != nil {
+		return nil, nil, ErrSeatsRequired
+	}
+
+	data, err := issuer.Policy.License(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := issuer.CertManager.FulfilLicenseRequest(req, data, issuer.Key, issuer.algorithm())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c, data, nil
+}
+
+// ServeHTTP handles requests made to the server.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleLicenses(w http.ResponseWriter, r *http.Request) {
+	req, ok := s.readRequest(w, r)
+	if !ok {
+		return
+	}
+
+	c, data, err := s.Issue(req)
+	if err != nil {
+		s.writeError(w, r, errorStatus(err), err)
+		return
+	}
+
+	s.logf("%s issued license %s for %s to %s", r.RemoteAddr, data.Meta.ID, req.Product, req.Fingerprint)
+	s.writeContainer(w, r, c)
+}
+
+// readRequest reads the license request posted to the server, writing an
+// error response if it is not valid.
+func (s *Server) readRequest(w http.ResponseWriter, r *http.Request) (*license.LicenseRequest, bool) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		s.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method))
+		return nil, false
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxRequestSize+1))
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, err)
+		return nil, false
+	}
+
+	if len(body) > MaxRequestSize {
+		s.writeError(w, r, http.StatusRequestEntityTooLarge, errors.New("the license request is too large"))
+		return nil, false
+	}
+
+	req, err := license.ParseLicenseRequest(body)
+	if err != nil {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid license request: %s", err))
+		return nil, false
+	}
+
+	return req, true
+}
+
+func (s *Server) writeContainer(w http.ResponseWriter, r *http.Request, c *license.Container) {
+	response, err := license.EncodeContainer(c)
+	if err != nil {
+		s.writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", LicenseContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// ErrorResponse is the body of the responses returned when a request
+// cannot be fulfilled.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	s.logf("%s %s %s: %d %s", r.RemoteAddr, r.Method, r.URL.Path, status, err)
+
+	data, _ := json.Marshal(&ErrorResponse{Error: err.Error()})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.Log != nil {
+		s.Log.Printf(format, args...)
+	}
+}
+
+func errorStatus(err error) int {
+	if _, ok := err.(*DeniedError); ok {
+		return http.StatusForbidden
+	}
+
+	switch err {
+	case ErrUnknownProduct, ErrNoSeatPool:
+		return http.StatusNotFound
+	case license.ErrNoSeats:
+		return http.StatusServiceUnavailable
+	case license.ErrSeatNotHeld, ErrSeatsRequired:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
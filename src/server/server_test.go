@@ -0,0 +1,431 @@
+package server_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/server"
+	"github.com/SierraSoftworks/Lithium/src/server/servertest"
+)
+
+func TestServerIssuesLicense(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	err = h.Templates.Put(&license.LicenseTemplate{
+		Name:         "standard",
+		ValidityDays: 30,
+		Payload: map[string]interface{}{
+			"seats": 5.0,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Issuer.Policy.(*server.TemplatePolicy).Allowed = []string{"standard"}
+
+	machine := h.Machine("machine")
+	response, err := h.Client().RequestLicense(machine, "standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := machine.InstallLicenseResponse(response, h.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Meta.Product != "testing" || data.Meta.LicenseTemplate != "standard" {
+		t.Errorf("expected the license to be issued for testing/standard, got %s/%s", data.Meta.Product, data.Meta.LicenseTemplate)
+	}
+
+	if data.Payload["seats"] != 5.0 {
+		t.Errorf("expected the template's payload to be applied, got %v", data.Payload)
+	}
+
+	if validity := data.Meta.ExpiresOn.Sub(data.Meta.ActivatesOn); validity < 29*24*time.Hour || validity > 31*24*time.Hour {
+		t.Errorf("expected the license to be valid for the template's 30 days, got %s", validity)
+	}
+
+	entries, err := h.Issuer.CertManager.Ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].LicenseID != data.Meta.ID {
+		t.Errorf("expected the license to be recorded in the ledger, got %d entries", len(entries))
+	}
+}
+
+func TestServerRejectsRequests(t *testing.T) {
+	h, err := servertest.New("testing", &server.TemplatePolicy{
+		Allowed: []string{"standard"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	_, err = h.Client().RequestLicense(h.Machine("machine"), "enterprise")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a request for a template which is not allowed to be forbidden, got %v", err)
+	}
+
+	_, err = h.Client().RequestLicense(h.Machine("machine"), "")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a request without a template to be forbidden, got %v", err)
+	}
+
+	other := h.Machine("other")
+	other.Product = "other"
+	_, err = h.Client().RequestLicense(other, "standard")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a request for an unknown product to be rejected, got %v", err)
+	}
+
+	res, err := http.Post(h.URL+server.LicensesPath, server.LicenseContentType, strings.NewReader("not a request"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected a malformed request to be rejected, got %d", res.StatusCode)
+	}
+
+	res, err = http.Get(h.URL + server.LicensesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("expected a GET request to be rejected, got %d", res.StatusCode)
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	h, err := servertest.NewTLS("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	if !strings.HasPrefix(h.URL, "https://") {
+		t.Fatalf("expected the server to use TLS, got %s", h.URL)
+	}
+
+	machine := h.Machine("machine")
+	response, err := h.Client().RequestLicense(machine, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = machine.InstallLicenseResponse(response, h.Root)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestServerSeats(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Issuer.Seats = license.NewSeatPool(1, time.Minute)
+
+	machine := h.Machine("machine")
+	response, err := h.Client().Checkout(machine, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := machine.InstallLicenseResponse(response, h.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if validity := data.Meta.ExpiresOn.Sub(data.Meta.ActivatesOn); validity > 2*time.Minute {
+		t.Errorf("expected the license to expire with the seat's lease, got %s", validity)
+	}
+
+	other := h.Machine("other")
+	_, err = h.Client().Checkout(other, "")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected a checkout from a full pool to be rejected, got %v", err)
+	}
+
+	_, err = h.Client().Renew(other, data.Meta.ID)
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusConflict {
+		t.Errorf("expected a renewal by another machine to be rejected, got %v", err)
+	}
+
+	err = h.Client().Release(other, data.Meta.ID)
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusConflict {
+		t.Errorf("expected a release by another machine to be rejected, got %v", err)
+	}
+
+	response, err = h.Client().Renew(machine, data.Meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renewed, err := machine.InstallLicenseResponse(response, h.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if renewed.Meta.ID != data.Meta.ID || renewed.Meta.ExpiresOn.Before(data.Meta.ExpiresOn) {
+		t.Errorf("expected the renewed license to extend license %s, got %s expiring %s", data.Meta.ID, renewed.Meta.ID, renewed.Meta.ExpiresOn)
+	}
+
+	err = h.Client().Release(machine, data.Meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = h.Client().Renew(machine, data.Meta.ID)
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusConflict {
+		t.Errorf("expected a released seat not to be renewed, got %v", err)
+	}
+
+	_, err = h.Client().Checkout(other, "")
+	if err != nil {
+		t.Errorf("expected a released seat to be available, got %v", err)
+	}
+}
+
+func TestServerSeatsRequired(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Issuer.Seats = license.NewSeatPool(1, time.Minute)
+
+	_, err = h.Client().RequestLicense(h.Machine("machine"), "")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusConflict {
+		t.Errorf("expected a license request for a product with a seat pool to be refused, got %v", err)
+	}
+
+	if available := h.Issuer.Seats.Available(); available != 1 {
+		t.Errorf("expected the refused request not to consume a seat, got %d available", available)
+	}
+
+	entries, err := h.Issuer.CertManager.Ledger.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("expected no license to be issued, got %d ledger entries", len(entries))
+	}
+}
+
+func TestServerSeatsClockSkew(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Issuer.Seats = license.NewSeatPool(1, time.Minute)
+
+	machine := h.Machine("machine")
+	body, err := machine.CreateLicenseRequest("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := license.ParseLicenseRequest(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.ClientTime = time.Now().AddDate(10, 0, 0)
+
+	c, seat, err := h.Server.Checkout(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privKey, err := machine.GetPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := c.License(privKey, h.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if limit := seat.ExpiresOn.Add(h.Issuer.Seats.Lease); data.Meta.ExpiresOn.After(limit) {
+		t.Errorf("expected the license to outlive its seat by no more than the lease, got %s for a seat expiring %s", data.Meta.ExpiresOn, seat.ExpiresOn)
+	}
+}
+
+func TestServerSeatsConcurrency(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Issuer.Seats = license.NewSeatPool(3, time.Minute)
+
+	machines := []*license.KeyManager{}
+	for i := 0; i < 8; i++ {
+		machine := h.Machine(fmt.Sprintf("machine-%d", i))
+		if _, err := machine.GetPublicKey(); err != nil {
+			t.Fatal(err)
+		}
+
+		machines = append(machines, machine)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(machines))
+	for _, machine := range machines {
+		wg.Add(1)
+		go func(machine *license.KeyManager) {
+			defer wg.Done()
+
+			_, err := h.Client().Checkout(machine, "")
+			errs <- err
+		}(machine)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	checkedOut := 0
+	for err := range errs {
+		if err == nil {
+			checkedOut++
+		} else if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected checkouts beyond the pool's size to be rejected, got %v", err)
+		}
+	}
+
+	if checkedOut != 3 {
+		t.Errorf("expected 3 seats to be checked out, got %d", checkedOut)
+	}
+}
+
+func TestServerWithoutSeats(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	_, err = h.Client().Checkout(h.Machine("machine"), "")
+	if e, ok := err.(*server.ResponseError); !ok || e.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a checkout without a seat pool to be rejected, got %v", err)
+	}
+
+	res, err := http.Post(h.URL+server.SeatsPath+"/id/steal", server.LicenseContentType, strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("expected an unknown seat action to be rejected, got %d", res.StatusCode)
+	}
+}
+
+func TestServerSeatsReplay(t *testing.T) {
+	h, err := servertest.New("testing", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Issuer.Seats = license.NewSeatPool(2, time.Minute)
+
+	machine := h.Machine("machine")
+	response, err := h.Client().Checkout(machine, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := machine.InstallLicenseResponse(response, h.Root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release, err := machine.CreateSeatRequest(data.Meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(path string, body []byte) int {
+		res, err := http.Post(h.URL+path, server.LicenseContentType, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		return res.StatusCode
+	}
+
+	if status := post(server.SeatPath(data.Meta.ID, "release"), release); status != http.StatusNoContent {
+		t.Fatalf("expected the seat to be released, got %d", status)
+	}
+
+	_, err = h.Client().Checkout(machine, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seats := h.Issuer.Seats.Seats()
+	if len(seats) != 1 {
+		t.Fatalf("expected a seat to be checked out, got %d", len(seats))
+	}
+
+	if status := post(server.SeatPath(data.Meta.ID, "release"), release); status != http.StatusForbidden {
+		t.Errorf("expected a replayed release to be refused, got %d", status)
+	}
+
+	renew, err := machine.CreateSeatRequest(data.Meta.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := post(server.SeatPath(seats[0].LicenseID, "renew"), renew); status != http.StatusForbidden {
+		t.Errorf("expected a request created for another seat to be refused, got %d", status)
+	}
+
+	body, err := machine.CreateSeatRequest(seats[0].LicenseID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := license.ParseLicenseRequest(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.ClientTime = time.Now().Add(-time.Hour)
+	if err := h.Server.Release(req, seats[0].LicenseID); err == nil {
+		t.Error("expected a stale release to be refused")
+	}
+
+	if available := h.Issuer.Seats.Available(); available != 1 {
+		t.Errorf("expected the refused requests not to release the seat, got %d available", available)
+	}
+}
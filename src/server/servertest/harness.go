@@ -0,0 +1,147 @@
+// Package servertest provides an in-process license server, issuing
+// licenses for a newly created product, for use when testing the clients
+// of a license server.
+package servertest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/SierraSoftworks/Lithium/src/license"
+	"github.com/SierraSoftworks/Lithium/src/server"
+)
+
+// KeySize is the size of the keys generated by the harness, which is kept
+// small so that tests run quickly.
+const KeySize = 1024
+
+// Harness is a license server listening on a local port, backed by a
+// product whose certificates and keys are held in a temporary folder.
+type Harness struct {
+	// URL is the base URL of the server.
+	URL string
+
+	Server  *server.Server
+	Issuer  *server.Issuer
+	Product *license.Product
+
+	// Root is the product's root certificate, which machines use to
+	// validate the licenses issued by the server.
+	Root *x509.Certificate
+
+	// Templates holds the product's license templates, which are used by
+	// the harness's default policy.
+	Templates *license.MemoryTemplateStore
+
+	// Dir is the temporary folder removed by Close.
+	Dir string
+
+	http *httptest.Server
+}
+
+// New starts a harness issuing licenses for a new product with the given
+// ID. If policy is nil, a server.TemplatePolicy using the harness's
+// Templates is used.
+func New(productID string, policy server.Policy) (*Harness, error) {
+	return start(productID, policy, httptest.NewServer)
+}
+
+// NewTLS starts a harness, as with New, which serves requests using TLS.
+// Clients must be created using Client to trust its certificate.
+func NewTLS(productID string, policy server.Policy) (*Harness, error) {
+	return start(productID, policy, httptest.NewTLSServer)
+}
+
+func start(productID string, policy server.Policy, listen func(http.Handler) *httptest.Server) (*Harness, error) {
+	dir, err := ioutil.TempDir(os.TempDir(), "lithium")
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Harness{
+		Product: &license.Product{
+			ID:           productID,
+			Name:         productID,
+			Organization: "Lithium Testing",
+		},
+		Templates: license.NewMemoryTemplateStore(),
+		Dir:       dir,
+	}
+
+	cm := license.NewCertManager(h.Product)
+	cm.Path = filepath.Join(dir, "licenses")
+	cm.Ledger = license.NewMemoryLedger()
+
+	key, err := rsa.GenerateKey(rand.Reader, KeySize)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	h.Root, err = cm.CreateRoot(key)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	err = cm.SetLocal(h.Root)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	if policy == nil {
+		policy = &server.TemplatePolicy{Templates: h.Templates}
+	}
+
+	h.Issuer = &server.Issuer{
+		CertManager: cm,
+		Key:         key,
+		Policy:      policy,
+	}
+
+	h.Server = server.New()
+	err = h.Server.AddIssuer(h.Issuer)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	h.http = listen(h.Server)
+	h.URL = h.http.URL
+	return h, nil
+}
+
+// Client returns a license server client for the harness.
+func (h *Harness) Client() *server.Client {
+	return &server.Client{
+		URL:        h.URL,
+		HTTPClient: h.http.Client(),
+	}
+}
+
+// Machine returns a KeyManager for the product, with the given machine
+// code, whose keys are held within the harness's folder. Each distinct
+// machine code identifies a different machine.
+func (h *Harness) Machine(machineCode string) *license.KeyManager {
+	km := license.NewKeyManager([]byte(machineCode))
+	km.Path = filepath.Join(h.Dir, "machines", machineCode)
+	km.Product = h.Product.ID
+	km.KeySize = KeySize
+	return km
+}
+
+// Close stops the server and removes the harness's folder.
+func (h *Harness) Close() {
+	if h.http != nil {
+		h.http.Close()
+	}
+
+	os.RemoveAll(h.Dir)
+}